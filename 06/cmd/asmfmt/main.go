@@ -0,0 +1,102 @@
+package main
+
+import (
+	"assembler/format"
+	"assembler/source"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+var write = flag.Bool("w", false, "Write the result to the file instead of stdout")
+var check = flag.Bool("check", false, "Only list the files that are not formatted, and exit with status 1 if there are any")
+
+func init() {
+	flag.Parse()
+
+	for _, name := range flag.Args() {
+		validateFileFormat(name, "asm")
+	}
+
+	if *write && flag.NArg() == 0 {
+		log.Fatalf("w needs files to write to")
+	}
+}
+
+func validateFileFormat(name string, format string) {
+	temp := strings.Split(name, ".")
+	if temp[len(temp)-1] != format {
+		log.Fatalf("Format of %s must be %s", name, format)
+	}
+}
+
+func main() {
+	if flag.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		formatted := formatSource(src, "<stdin>")
+		if *check {
+			if !bytes.Equal(src, formatted) {
+				fmt.Println("<stdin>")
+				os.Exit(1)
+			}
+
+			return
+		}
+		os.Stdout.Write(formatted)
+
+		return
+	}
+
+	unformatted := false
+	for _, name := range flag.Args() {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Fatalf("Can't be open file: %s", name)
+		}
+
+		formatted := formatSource(src, name)
+		switch {
+		case *check:
+			if !bytes.Equal(src, formatted) {
+				fmt.Println(name)
+				unformatted = true
+			}
+		case *write:
+			if bytes.Equal(src, formatted) {
+				continue
+			}
+			if err := ioutil.WriteFile(name, formatted, 0644); err != nil {
+				log.Fatalf("Can't write file: %s", name)
+			}
+		default:
+			os.Stdout.Write(formatted)
+		}
+	}
+
+	if unformatted {
+		os.Exit(1)
+	}
+}
+
+func formatSource(src []byte, name string) []byte {
+	lines, err := source.ReadLines(bytes.NewReader(src), name)
+	if err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+
+	var b bytes.Buffer
+	for _, line := range format.Lines(lines) {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes()
+}
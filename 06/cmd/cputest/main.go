@@ -0,0 +1,47 @@
+package main
+
+import (
+	"assembler/tst"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func init() {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		log.Fatalf("usage: cputest script.tst...")
+	}
+
+	for _, name := range flag.Args() {
+		validateFileFormat(name, "tst")
+	}
+}
+
+func validateFileFormat(name string, format string) {
+	temp := strings.Split(name, ".")
+	if temp[len(temp)-1] != format {
+		log.Fatalf("Format of %s must be %s", name, format)
+	}
+}
+
+func main() {
+	failed := false
+	for _, name := range flag.Args() {
+		if err := tst.RunFile(name); err != nil {
+			fmt.Printf("FAIL %s: %v\n", name, err)
+			failed = true
+
+			continue
+		}
+
+		fmt.Printf("PASS %s\n", name)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
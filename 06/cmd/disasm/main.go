@@ -0,0 +1,91 @@
+package main
+
+import (
+	"assembler/disasm"
+	"assembler/rom"
+	"assembler/symbol"
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var binary = flag.String("hack", "", "Binary file location")
+var symbolMap = flag.String("sym", "", "Symbol map location (.sym or .sym.json), to restore label and variable names")
+var output = flag.String("out", "", "Assembly file location, stdout when empty")
+
+func init() {
+	flag.Parse()
+
+	if *binary == "" {
+		log.Fatalf("hack can't be empty")
+	}
+
+	validateFileFormat(binary, "hack")
+}
+
+func validateFileFormat(name *string, format string) {
+	temp := strings.Split(*name, ".")
+	if temp[len(temp)-1] != format {
+		log.Fatalf("Format of %s must be %s", *name, format)
+	}
+}
+
+func main() {
+	file, err := os.Open(*binary)
+	if err != nil {
+		log.Fatalf("Can't be open file: %s", *binary)
+	}
+	defer file.Close()
+
+	words, err := rom.ReadHack(file)
+	if err != nil {
+		log.Fatalf("%s: %v", *binary, err)
+	}
+
+	var symbols []symbol.Entry
+	if *symbolMap != "" {
+		symbols = readSymbolMap(*symbolMap)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		asmFile, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Can't create file: %s", *output)
+		}
+		defer asmFile.Close()
+
+		out = asmFile
+	}
+
+	writer := bufio.NewWriter(out)
+	if err := disasm.Disassemble(writer, words, symbols); err != nil {
+		log.Fatalf("%s: %v", *binary, err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+func readSymbolMap(loc string) []symbol.Entry {
+	file, err := os.Open(loc)
+	if err != nil {
+		log.Fatalf("Can't be open file: %s", loc)
+	}
+	defer file.Close()
+
+	var symbols []symbol.Entry
+	if strings.HasSuffix(loc, ".json") {
+		symbols, err = symbol.ReadMapJSON(file)
+	} else {
+		symbols, err = symbol.ReadMap(file)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", loc, err)
+	}
+
+	return symbols
+}
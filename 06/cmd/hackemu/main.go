@@ -0,0 +1,316 @@
+package main
+
+import (
+	"assembler/emulator"
+	"assembler/keyboard"
+	"assembler/rom"
+	"assembler/screen"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var binary = flag.String("hack", "", "Binary file location")
+var cycles = flag.Int("cycles", 0, "Number of instructions to execute, 0 to run until the program halts")
+var set = flag.String("set", "", "RAM values to start with, as address=value pairs separated by commas, like 0=3,1=7")
+var dump = flag.String("dump", "", "RAM to print after running, as addresses or ranges separated by commas, like 0-2,16")
+var pngFile = flag.String("png", "", "PNG file to write the screen to after running, or at the cycles of -snap")
+var snap = flag.String("snap", "", "Cycles to write a PNG of the screen at, separated by commas. The cycle is added to the name of -png, like Pong-1000.png")
+var gifFile = flag.String("gif", "", "Animated GIF file to record the screen to")
+var every = flag.Int("every", 100000, "Number of cycles between the frames of -gif")
+var delay = flag.Int("delay", 5, "Time each frame of -gif is shown, in 100ths of a second")
+var keys = flag.String("keys", "", "Keyboard input script to play, with lines like: hold LEFT 1000 5000, type 20000 \"hi\\n\"")
+var reads = flag.String("reads", "", "File to record the keys the program reads from KBD to, - for stdout")
+
+var snaps []int
+var script *keyboard.Script
+
+func init() {
+	flag.Parse()
+
+	if *binary == "" {
+		log.Fatalf("hack can't be empty")
+	}
+
+	validateFileFormat(*binary, "hack")
+
+	if *snap != "" {
+		if *pngFile == "" {
+			log.Fatalf("snap needs png")
+		}
+		snaps = parseCycles(*snap)
+
+		if last := snaps[len(snaps)-1]; *cycles > 0 && last > *cycles {
+			log.Fatalf("snap: cycle %d is after the last of %d cycles", last, *cycles)
+		}
+	}
+	if *pngFile != "" {
+		validateFileFormat(*pngFile, "png")
+	}
+	if *keys != "" {
+		src, err := ioutil.ReadFile(*keys)
+		if err != nil {
+			log.Fatalf("Can't be open file: %s", *keys)
+		}
+
+		script, err = keyboard.ParseScript(string(src))
+		if err != nil {
+			log.Fatalf("%s: %v", *keys, err)
+		}
+	}
+	if *gifFile != "" {
+		validateFileFormat(*gifFile, "gif")
+
+		if *every <= 0 {
+			log.Fatalf("every must be a positive number of cycles")
+		}
+	}
+}
+
+func parseCycles(list string) []int {
+	var cycles []int
+	for _, item := range strings.Split(list, ",") {
+		cycle, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || cycle < 0 {
+			log.Fatalf("snap: %q is not a cycle", item)
+		}
+		cycles = append(cycles, cycle)
+	}
+	sort.Ints(cycles)
+
+	return cycles
+}
+
+func validateFileFormat(name string, format string) {
+	temp := strings.Split(name, ".")
+	if temp[len(temp)-1] != format {
+		log.Fatalf("Format of %s must be %s", name, format)
+	}
+}
+
+func main() {
+	file, err := os.Open(*binary)
+	if err != nil {
+		log.Fatalf("Can't be open file: %s", *binary)
+	}
+	defer file.Close()
+
+	words, err := rom.ReadHack(file)
+	if err != nil {
+		log.Fatalf("%s: %v", *binary, err)
+	}
+
+	computer, err := emulator.New(words)
+	if err != nil {
+		log.Fatalf("%s: %v", *binary, err)
+	}
+
+	if *set != "" {
+		setRAM(computer, *set)
+	}
+
+	var recorded []keyboard.Read
+	if *reads != "" {
+		computer.OnKeyRead = func(cycle int, key uint16) {
+			if n := len(recorded); n == 0 || recorded[n-1].Key != key {
+				recorded = append(recorded, keyboard.Read{Cycle: cycle, Key: key})
+			}
+		}
+	}
+
+	if err := run(computer); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if *reads != "" {
+		if err := writeReads(recorded); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	state := "running"
+	if computer.Halted() {
+		state = "halted"
+	}
+	fmt.Printf("%s after %d cycles: PC=%d A=%d D=%d\n", state, computer.Cycles, computer.PC, int16(computer.A), int16(computer.D))
+
+	if *dump != "" {
+		dumpRAM(computer, *dump)
+	}
+}
+
+// run runs the computer for -cycles, playing the keyboard script and writing
+// the screen to the PNG and GIF files on the way. Snapshots after the
+// program halts show its last screen.
+func run(computer *emulator.Computer) error {
+	var recorder *screen.Recorder
+	nextFrame := -1
+	if *gifFile != "" {
+		recorder = screen.NewRecorder(*delay)
+		nextFrame = 0
+	}
+
+	for {
+		if recorder != nil && computer.Cycles == nextFrame {
+			recorder.Add(computer)
+			nextFrame += *every
+		}
+		for len(snaps) > 0 && (snaps[0] <= computer.Cycles || computer.Halted()) {
+			if err := writePNG(computer, snapName(snaps[0])); err != nil {
+				return err
+			}
+			snaps = snaps[1:]
+		}
+
+		if computer.Halted() || (*cycles > 0 && computer.Cycles >= *cycles) {
+			break
+		}
+
+		nextKey := -1
+		if script != nil {
+			computer.SetKey(script.Key(computer.Cycles))
+			nextKey = script.Next(computer.Cycles)
+		}
+
+		// run up to whichever comes first: a snapshot, a frame, a key or the end
+		target := *cycles
+		for _, next := range []int{nextFrame, firstSnap(), nextKey} {
+			if next > computer.Cycles && (target <= 0 || next < target) {
+				target = next
+			}
+		}
+
+		n := 0
+		if target > 0 {
+			n = target - computer.Cycles
+		}
+		if err := computer.Run(n); err != nil {
+			return err
+		}
+	}
+
+	if recorder != nil {
+		if recorder.Frames() == 0 || nextFrame-*every != computer.Cycles {
+			recorder.Add(computer)
+		}
+		if err := writeGIF(recorder); err != nil {
+			return err
+		}
+	}
+
+	if *pngFile != "" && *snap == "" {
+		return writePNG(computer, *pngFile)
+	}
+
+	return nil
+}
+
+func firstSnap() int {
+	if len(snaps) == 0 {
+		return -1
+	}
+
+	return snaps[0]
+}
+
+// snapName adds cycle to the name of the PNG file.
+func snapName(cycle int) string {
+	ext := filepath.Ext(*pngFile)
+
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(*pngFile, ext), cycle, ext)
+}
+
+func writePNG(computer *emulator.Computer, name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := screen.WritePNG(file, computer); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func writeGIF(recorder *screen.Recorder) error {
+	file, err := os.Create(*gifFile)
+	if err != nil {
+		return err
+	}
+
+	if err := recorder.Encode(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// writeReads writes a line of the cycle, the code and the name of every key
+// the program read, when it differs from the key read before.
+func writeReads(recorded []keyboard.Read) error {
+	out := os.Stdout
+	if *reads != "-" {
+		file, err := os.Create(*reads)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	for _, read := range recorded {
+		if _, err := fmt.Fprintf(out, "%d %d %s\n", read.Cycle, read.Key, keyboard.Name(read.Key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setRAM(computer *emulator.Computer, list string) {
+	for _, pair := range strings.Split(list, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			log.Fatalf("set: %q is not address=value", pair)
+		}
+
+		address := parseAddress(parts[0])
+		value, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 32)
+		if err != nil || value < -32768 || value > 65535 {
+			log.Fatalf("set: %q is not a 16 bit value", parts[1])
+		}
+
+		computer.RAM[address] = uint16(value)
+	}
+}
+
+func dumpRAM(computer *emulator.Computer, list string) {
+	for _, item := range strings.Split(list, ",") {
+		from, to := item, item
+		if i := strings.Index(item, "-"); i >= 0 {
+			from, to = item[:i], item[i+1:]
+		}
+
+		for address := parseAddress(from); address <= parseAddress(to); address++ {
+			fmt.Printf("RAM[%d] = %d\n", address, int16(computer.RAM[address]))
+		}
+	}
+}
+
+func parseAddress(s string) int {
+	address, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || address < 0 || address >= emulator.RAMSize {
+		log.Fatalf("%q is not a RAM address", s)
+	}
+
+	return address
+}
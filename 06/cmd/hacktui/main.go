@@ -0,0 +1,197 @@
+package main
+
+import (
+	"assembler/emulator"
+	"assembler/rom"
+	"assembler/tui"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var binary = flag.String("hack", "", "Binary file location")
+var speed = flag.Int("speed", 100000, "Number of instructions to execute per frame")
+var fps = flag.Int("fps", 30, "Frames drawn per second")
+var scale = flag.Int("scale", 0, "Pixels per braille dot in each direction, 0 to fit the terminal")
+var watch = flag.String("watch", "0-4", "RAM to show, as addresses or ranges separated by commas, like 0-2,16,24576")
+var hold = flag.Duration("hold", 150*time.Millisecond, "Time a key stays pressed. Terminals send no key releases, so a key held down is a key pressed again and again")
+
+var addresses []int
+
+func init() {
+	flag.Parse()
+
+	if *binary == "" {
+		log.Fatalf("hack can't be empty")
+	}
+
+	validateFileFormat(*binary, "hack")
+
+	if *speed <= 0 || *fps <= 0 {
+		log.Fatalf("speed and fps must be positive")
+	}
+	if *watch != "" {
+		addresses = parseAddresses(*watch)
+	}
+}
+
+func validateFileFormat(name string, format string) {
+	temp := strings.Split(name, ".")
+	if temp[len(temp)-1] != format {
+		log.Fatalf("Format of %s must be %s", name, format)
+	}
+}
+
+func parseAddresses(list string) []int {
+	var addresses []int
+	for _, item := range strings.Split(list, ",") {
+		from, to := item, item
+		if i := strings.Index(item, "-"); i >= 0 {
+			from, to = item[:i], item[i+1:]
+		}
+
+		for address := parseAddress(from); address <= parseAddress(to); address++ {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+func parseAddress(s string) int {
+	address, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || address < 0 || address >= emulator.RAMSize {
+		log.Fatalf("%q is not a RAM address", s)
+	}
+
+	return address
+}
+
+func main() {
+	file, err := os.Open(*binary)
+	if err != nil {
+		log.Fatalf("Can't be open file: %s", *binary)
+	}
+	defer file.Close()
+
+	words, err := rom.ReadHack(file)
+	if err != nil {
+		log.Fatalf("%s: %v", *binary, err)
+	}
+
+	computer, err := emulator.New(words)
+	if err != nil {
+		log.Fatalf("%s: %v", *binary, err)
+	}
+
+	view := &tui.View{Scale: *scale, Watch: addresses}
+	if view.Scale <= 0 {
+		view.Scale = 2
+		if columns, rows, err := tui.Size(int(os.Stdout.Fd())); err == nil {
+			view.Scale = tui.FitScale(columns, rows)
+		}
+	}
+
+	restore, err := tui.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		log.Fatalf("can't use the terminal: %v", err)
+	}
+
+	// alternate screen, hidden cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l\x1b[2J")
+	err = run(computer, view)
+	fmt.Print("\x1b[?25h\x1b[?1049l")
+	restore()
+
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Printf("quit after %d cycles\n", computer.Cycles)
+}
+
+// run runs the computer a frame at a time until Ctrl-C or Ctrl-Q, and keeps
+// the last pressed key in KBD for the hold time.
+func run(computer *emulator.Computer, view *tui.View) error {
+	input := make(chan []byte)
+	go readInput(input)
+
+	ticker := time.NewTicker(time.Second / time.Duration(*fps))
+	defer ticker.Stop()
+
+	var released time.Time
+	var failure error
+	for {
+		select {
+		case b, ok := <-input:
+			if !ok {
+				return nil
+			}
+
+			keys, quit := tui.DecodeKeys(b)
+			if quit {
+				return nil
+			}
+			if len(keys) > 0 {
+				computer.SetKey(keys[len(keys)-1])
+				released = time.Now().Add(*hold)
+			}
+		case now := <-ticker.C:
+			if computer.RAM[emulator.KBD] != 0 && now.After(released) {
+				computer.SetKey(0)
+			}
+
+			if failure == nil && !computer.Halted() {
+				failure = computer.Run(*speed)
+			}
+
+			draw(view.Render(computer, status(computer, failure)))
+		}
+	}
+}
+
+func readInput(input chan<- []byte) {
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(input)
+			return
+		}
+
+		b := make([]byte, n)
+		copy(b, buf[:n])
+		input <- b
+	}
+}
+
+func status(computer *emulator.Computer, failure error) string {
+	switch {
+	case failure != nil:
+		return failure.Error()
+	case computer.Halted():
+		return "halted, Ctrl-C quits"
+	}
+
+	return "running, Ctrl-C quits"
+}
+
+// draw writes lines from the top of the terminal, clearing what is left of
+// the previous frame.
+func draw(lines []string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+
+	os.Stdout.WriteString(b.String())
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"assembler/code"
+	"assembler/object"
+	"assembler/rom"
+	"assembler/symbol"
+	"flag"
+	"log"
+	"os"
+	"strings"
+)
+
+var output = flag.String("out", "", "Output file location, named after the first object when empty")
+var outputFormat = flag.String("format", "hack", "Output format: "+strings.Join(rom.Formats(), ", "))
+var variableLimit = flag.Int("varlimit", symbol.DefaultVariableLimit, "First RAM address variables can't be allocated at, SCREEN by default")
+
+func init() {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		log.Fatalf("usage: link [-out file] [-format format] [-varlimit address] module.hobj...")
+	}
+
+	for _, name := range flag.Args() {
+		validateFileFormat(name, "hobj")
+	}
+
+	if _, ok := rom.Lookup(*outputFormat); !ok {
+		log.Fatalf("format must be one of %s, not %s", strings.Join(rom.Formats(), ", "), *outputFormat)
+	}
+
+	if *variableLimit <= 16 || *variableLimit > code.MaxAddress+1 {
+		log.Fatalf("varlimit must be between 17 and %d, not %d", code.MaxAddress+1, *variableLimit)
+	}
+}
+
+func validateFileFormat(name string, format string) {
+	temp := strings.Split(name, ".")
+	if temp[len(temp)-1] != format {
+		log.Fatalf("Format of %s must be %s", name, format)
+	}
+}
+
+func main() {
+	objs := make([]*object.Object, 0, flag.NArg())
+	for _, name := range flag.Args() {
+		objs = append(objs, readObject(name))
+	}
+
+	words, _, err := object.Link(objs, *variableLimit)
+	if err != nil {
+		log.Fatalf("link failed:\n%v", err)
+	}
+
+	writer, _ := rom.Lookup(*outputFormat)
+
+	outName := *output
+	if outName == "" {
+		outName = strings.TrimSuffix(flag.Arg(0), ".hobj") + "." + writer.Extension()
+	}
+
+	outFile, err := os.Create(outName)
+	if err != nil {
+		log.Fatalf("Can't create file: %s", outName)
+	}
+	defer outFile.Close()
+
+	if err := writer.Write(outFile, words); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+func readObject(name string) *object.Object {
+	file, err := os.Open(name)
+	if err != nil {
+		log.Fatalf("Can't be open file: %s", name)
+	}
+	defer file.Close()
+
+	obj, err := object.Read(file)
+	if err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+
+	return obj
+}
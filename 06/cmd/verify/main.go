@@ -0,0 +1,46 @@
+package main
+
+import (
+	"assembler/verify"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+var assembly = flag.String("asm", "", "Assembly file location")
+var expected = flag.String("expect", "", "Expected program: a .hack file, or a .asm file to assemble")
+
+func init() {
+	flag.Parse()
+
+	if *assembly == "" || *expected == "" {
+		log.Fatalf("usage: verify -asm file.asm -expect file.hack|file.asm")
+	}
+
+	validateFileFormat(*assembly, "asm")
+	if !strings.HasSuffix(*expected, ".asm") {
+		validateFileFormat(*expected, "hack")
+	}
+}
+
+func validateFileFormat(name string, format string) {
+	temp := strings.Split(name, ".")
+	if temp[len(temp)-1] != format {
+		log.Fatalf("Format of %s must be %s", name, format)
+	}
+}
+
+func main() {
+	err := verify.File(*assembly, *expected)
+	if m, ok := err.(*verify.Mismatch); ok {
+		fmt.Printf("%s and %s: %v\n", *assembly, *expected, m)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	fmt.Printf("%s matches %s\n", *assembly, *expected)
+}
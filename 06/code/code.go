@@ -1,69 +1,135 @@
 package code
 
 var (
-	destTable = map[string]string{
-		"null0": "000",
-		"M":     "001",
-		"D":     "010",
-		"MD":    "011",
-		"A":     "100",
-		"AM":    "101",
-		"AD":    "110",
-		"AMD":   "111",
+	destTable = map[string]uint16{
+		"":    0b000,
+		"M":   0b001,
+		"D":   0b010,
+		"MD":  0b011,
+		"A":   0b100,
+		"AM":  0b101,
+		"AD":  0b110,
+		"AMD": 0b111,
 	}
-	jumpTable = map[string]string{
-		"null": "000",
-		"JGT":  "001",
-		"JEQ":  "010",
-		"JGE":  "011",
-		"JLT":  "100",
-		"JNE":  "101",
-		"JLE":  "110",
-		"JMP":  "111",
+	jumpTable = map[string]uint16{
+		"":    0b000,
+		"JGT": 0b001,
+		"JEQ": 0b010,
+		"JGE": 0b011,
+		"JLT": 0b100,
+		"JNE": 0b101,
+		"JLE": 0b110,
+		"JMP": 0b111,
 	}
-	compTable = map[string]string{
-		"0":   "0101010",
-		"1":   "0111111",
-		"-1":  "0111010",
-		"D":   "0001100",
-		"A":   "0110000",
-		"!D":  "0001101",
-		"!A":  "0110001",
-		"-D":  "0001111",
-		"-A":  "0110011",
-		"D+1": "0011111",
-		"A+1": "0110111",
-		"D-1": "0001110",
-		"A-1": "0110010",
-		"D+A": "0000010",
-		"D-A": "0010011",
-		"A-D": "0000111",
-		"D&A": "0000000",
-		"D|A": "0010101",
-		"M":   "1110000",
-		"!M":  "1110001",
-		"-M":  "1110011",
-		"M+1": "1110111",
-		"M-1": "1110010",
-		"D+M": "1000010",
-		"D-M": "1010011",
-		"M-D": "1000111",
-		"D&M": "1000000",
-		"D|M": "1010101",
+	compTable = map[string]uint16{
+		"0":   0b0101010,
+		"1":   0b0111111,
+		"-1":  0b0111010,
+		"D":   0b0001100,
+		"A":   0b0110000,
+		"!D":  0b0001101,
+		"!A":  0b0110001,
+		"-D":  0b0001111,
+		"-A":  0b0110011,
+		"D+1": 0b0011111,
+		"A+1": 0b0110111,
+		"D-1": 0b0001110,
+		"A-1": 0b0110010,
+		"D+A": 0b0000010,
+		"D-A": 0b0010011,
+		"A-D": 0b0000111,
+		"D&A": 0b0000000,
+		"D|A": 0b0010101,
+		"M":   0b1110000,
+		"!M":  0b1110001,
+		"-M":  0b1110011,
+		"M+1": 0b1110111,
+		"M-1": 0b1110010,
+		"D+M": 0b1000010,
+		"D-M": 0b1010011,
+		"M-D": 0b1000111,
+		"D&M": 0b1000000,
+		"D|M": 0b1010101,
 	}
 )
 
-func Dest(mnemonic string) (string, bool) {
-	binaryCode, ok := destTable[mnemonic]
-	return binaryCode, ok
+// extendedCompTable holds the shifts of the extended Hack ALU. They are
+// encoded with the 101 prefix instead of 111, as in the CPU emulator.
+var extendedCompTable = map[string]uint16{
+	"A<<": 0b0100000,
+	"D<<": 0b0110000,
+	"M<<": 0b1100000,
+	"A>>": 0b0000000,
+	"D>>": 0b0010000,
+	"M>>": 0b1000000,
 }
 
-func Comp(mnemonic string) (string, bool) {
-	binaryCode, ok := compTable[mnemonic]
-	return binaryCode, ok
+var (
+	destMnemonics = reverse(destTable)
+	compMnemonics = reverse(compTable)
+	jumpMnemonics = reverse(jumpTable)
+
+	extendedCompMnemonics = reverse(extendedCompTable)
+)
+
+func reverse(table map[string]uint16) map[uint16]string {
+	reversed := make(map[uint16]string, len(table))
+	for mnemonic, bits := range table {
+		reversed[bits] = mnemonic
+	}
+
+	return reversed
+}
+
+// Dest returns the 3 dest bits of a C-Instruction. The null destination is "".
+func Dest(mnemonic string) (uint16, bool) {
+	bits, ok := destTable[mnemonic]
+	return bits, ok
+}
+
+// Comp returns the a-bit and the 6 c-bits of a C-Instruction.
+func Comp(mnemonic string) (uint16, bool) {
+	bits, ok := compTable[mnemonic]
+	return bits, ok
+}
+
+// ExtendedComp is Comp for the shifts of the extended Hack ALU.
+func ExtendedComp(mnemonic string) (uint16, bool) {
+	bits, ok := extendedCompTable[mnemonic]
+	return bits, ok
+}
+
+func IsExtended(comp string) bool {
+	_, ok := extendedCompTable[comp]
+	return ok
+}
+
+// Jump returns the 3 jump bits of a C-Instruction. The null jump is "".
+func Jump(mnemonic string) (uint16, bool) {
+	bits, ok := jumpTable[mnemonic]
+	return bits, ok
+}
+
+// DestMnemonic is the inverse of Dest.
+func DestMnemonic(bits uint16) (string, bool) {
+	mnemonic, ok := destMnemonics[bits]
+	return mnemonic, ok
+}
+
+// CompMnemonic is the inverse of Comp.
+func CompMnemonic(bits uint16) (string, bool) {
+	mnemonic, ok := compMnemonics[bits]
+	return mnemonic, ok
+}
+
+// ExtendedCompMnemonic is the inverse of ExtendedComp.
+func ExtendedCompMnemonic(bits uint16) (string, bool) {
+	mnemonic, ok := extendedCompMnemonics[bits]
+	return mnemonic, ok
 }
 
-func Jump(mnemonic string) (string, bool) {
-	binaryCode, ok := jumpTable[mnemonic]
-	return binaryCode, ok
+// JumpMnemonic is the inverse of Jump.
+func JumpMnemonic(bits uint16) (string, bool) {
+	mnemonic, ok := jumpMnemonics[bits]
+	return mnemonic, ok
 }
@@ -0,0 +1,92 @@
+package code
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MaxAddress is the largest constant an A-Instruction can hold.
+const MaxAddress = 0x7FFF
+
+// ROMSize is the number of instructions the Hack ROM holds.
+const ROMSize = 32768
+
+// Instruction is a single Hack machine instruction.
+type Instruction interface {
+	Encode() uint16
+	String() string
+}
+
+// AInstruction loads Value into the A register.
+type AInstruction struct {
+	Value uint16
+}
+
+func (a AInstruction) Encode() uint16 {
+	return a.Value & MaxAddress
+}
+
+func (a AInstruction) String() string {
+	return "@" + strconv.Itoa(int(a.Value&MaxAddress))
+}
+
+// CInstruction holds the mnemonics of a computation. Dest and Jump are ""
+// when they are null. Encode expects mnemonics that are in the code tables,
+// and uses the 101 prefix for the extended shifts.
+type CInstruction struct {
+	Dest string
+	Comp string
+	Jump string
+}
+
+func (c CInstruction) Encode() uint16 {
+	dest, _ := Dest(c.Dest)
+	jump, _ := Jump(c.Jump)
+
+	if comp, ok := ExtendedComp(c.Comp); ok {
+		return 0b101<<13 | comp<<6 | dest<<3 | jump
+	}
+
+	comp, _ := Comp(c.Comp)
+
+	return 0b111<<13 | comp<<6 | dest<<3 | jump
+}
+
+func (c CInstruction) String() string {
+	s := c.Comp
+	if c.Dest != "" {
+		s = c.Dest + "=" + s
+	}
+	if c.Jump != "" {
+		s += ";" + c.Jump
+	}
+
+	return s
+}
+
+// Decode is the inverse of Encode.
+func Decode(word uint16) (Instruction, error) {
+	if word&0x8000 == 0 {
+		return AInstruction{Value: word}, nil
+	}
+
+	var (
+		comp string
+		ok   bool
+	)
+	switch word >> 13 {
+	case 0b111:
+		comp, ok = CompMnemonic(word >> 6 & 0b1111111)
+	case 0b101:
+		comp, ok = ExtendedCompMnemonic(word >> 6 & 0b1111111)
+	default:
+		return nil, fmt.Errorf("%016b is not a valid C-Instruction", word)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%016b has unknown comp bits %07b", word, word>>6&0b1111111)
+	}
+	dest, _ := DestMnemonic(word >> 3 & 0b111)
+	jump, _ := JumpMnemonic(word & 0b111)
+
+	return CInstruction{Dest: dest, Comp: comp, Jump: jump}, nil
+}
@@ -0,0 +1,80 @@
+package code
+
+import "testing"
+
+func TestEncodeDecode(t *testing.T) {
+	var comps []string
+	for comp := range compTable {
+		comps = append(comps, comp)
+	}
+	for comp := range extendedCompTable {
+		comps = append(comps, comp)
+	}
+	if len(comps) != 28+6 {
+		t.Fatalf("got %d comps, want 28 and 6 shifts", len(comps))
+	}
+
+	words := make(map[uint16]string)
+	for _, comp := range comps {
+		for dest := range destTable {
+			for jump := range jumpTable {
+				c := CInstruction{Dest: dest, Comp: comp, Jump: jump}
+				word := c.Encode()
+
+				if other, ok := words[word]; ok {
+					t.Fatalf("%s and %s are both %016b", c, other, word)
+				}
+				words[word] = c.String()
+
+				got, err := Decode(word)
+				if err != nil {
+					t.Fatalf("%s: %v", c, err)
+				}
+				if got != c {
+					t.Fatalf("%s is %016b, which decodes to %s", c, word, got)
+				}
+			}
+		}
+	}
+}
+
+// The words of the Hack specification, and of the shifts as the CPU
+// emulator encodes them.
+func TestKnownWords(t *testing.T) {
+	tests := []struct {
+		inst Instruction
+		word uint16
+	}{
+		{AInstruction{Value: 0}, 0b0000000000000000},
+		{AInstruction{Value: MaxAddress}, 0b0111111111111111},
+		{CInstruction{Comp: "0", Jump: "JMP"}, 0b1110101010000111},
+		{CInstruction{Dest: "D", Comp: "D+A"}, 0b1110000010010000},
+		{CInstruction{Dest: "M", Comp: "M-D"}, 0b1111000111001000},
+		{CInstruction{Dest: "AMD", Comp: "!M", Jump: "JLE"}, 0b1111110001111110},
+		{CInstruction{Dest: "D", Comp: "D<<"}, 0b1010110000010000},
+		{CInstruction{Dest: "A", Comp: "A<<"}, 0b1010100000100000},
+		{CInstruction{Dest: "M", Comp: "M<<"}, 0b1011100000001000},
+		{CInstruction{Dest: "D", Comp: "D>>"}, 0b1010010000010000},
+		{CInstruction{Dest: "A", Comp: "A>>"}, 0b1010000000100000},
+		{CInstruction{Dest: "M", Comp: "M>>", Jump: "JEQ"}, 0b1011000000001010},
+	}
+
+	for _, test := range tests {
+		if got := test.inst.Encode(); got != test.word {
+			t.Errorf("%s: got %016b, want %016b", test.inst, got, test.word)
+		}
+
+		got, err := Decode(test.word)
+		if err != nil || got != test.inst {
+			t.Errorf("%016b: got %v, %v, want %s", test.word, got, err, test.inst)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, word := range []uint16{0b1100000000000000, 0b1000000000000000, 0b1110000001000000} {
+		if inst, err := Decode(word); err == nil {
+			t.Errorf("%016b: got %s, want an error", word, inst)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package code
+
+import "strings"
+
+// NormalizeDest returns the spelling of a destination that is in the dest
+// table, so that DM, MA or DAM are read as MD, AM and AMD. Whitespace is
+// ignored. It reports false when a letter is not A, D or M or is repeated.
+func NormalizeDest(mnemonic string) (string, bool) {
+	mnemonic = removeSpace(mnemonic)
+	if _, ok := destTable[mnemonic]; ok {
+		return mnemonic, true
+	}
+
+	var a, d, m int
+	for _, c := range mnemonic {
+		switch c {
+		case 'A':
+			a++
+		case 'D':
+			d++
+		case 'M':
+			m++
+		default:
+			return "", false
+		}
+	}
+	if a > 1 || d > 1 || m > 1 {
+		return "", false
+	}
+
+	// the table orders the letters A, M, D
+	canonical := strings.Repeat("A", a) + strings.Repeat("M", m) + strings.Repeat("D", d)
+
+	return canonical, true
+}
+
+// NormalizeComp returns the spelling of a computation that is in the comp
+// table or the extended comp table. Whitespace is ignored and the operands
+// of +, & and | may be in either order, so A+D, M&D and 1+D are read as D+A,
+// D&M and D+1.
+func NormalizeComp(mnemonic string) (string, bool) {
+	mnemonic = removeSpace(mnemonic)
+	if _, ok := compTable[mnemonic]; ok {
+		return mnemonic, true
+	}
+	if _, ok := extendedCompTable[mnemonic]; ok {
+		return mnemonic, true
+	}
+
+	if i := strings.IndexAny(mnemonic, "+&|"); i > 0 {
+		swapped := mnemonic[i+1:] + mnemonic[i:i+1] + mnemonic[:i]
+		if _, ok := compTable[swapped]; ok {
+			return swapped, true
+		}
+	}
+
+	return "", false
+}
+
+// NormalizeJump returns mnemonic without whitespace when it is in the jump
+// table.
+func NormalizeJump(mnemonic string) (string, bool) {
+	mnemonic = removeSpace(mnemonic)
+	_, ok := jumpTable[mnemonic]
+
+	return mnemonic, ok
+}
+
+func removeSpace(s string) string {
+	if !strings.ContainsAny(s, " \t") {
+		return s
+	}
+
+	return strings.Join(strings.Fields(s), "")
+}
@@ -0,0 +1,120 @@
+package disasm
+
+import (
+	"assembler/code"
+	"assembler/symbol"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Disassemble writes Hack assembly for words to w. An A-Instruction that is
+// immediately followed by a jump gets a label for its target, taken from
+// symbols when it has a label at that address and invented otherwise.
+// Variables from symbols replace constants only where reassembling would
+// allocate the same address again, so the output always reassembles to
+// the identical binary.
+func Disassemble(w io.Writer, words []uint16, symbols []symbol.Entry) error {
+	insts := make([]code.Instruction, len(words))
+	for address, word := range words {
+		inst, err := code.Decode(word)
+		if err != nil {
+			return fmt.Errorf("address %d: %v", address, err)
+		}
+		insts[address] = inst
+	}
+
+	labels := labelNames(insts, symbols)
+	variables := make(map[int]string)
+	for _, entry := range symbols {
+		if _, exist := variables[entry.Address]; entry.Kind == symbol.VARIABLE && !exist {
+			variables[entry.Address] = entry.Name
+		}
+	}
+
+	allocated := make(map[string]bool)
+	nextVariable := symbol.FirstVariable
+
+	var b strings.Builder
+	for address, inst := range insts {
+		if label, ok := labels[address]; ok {
+			fmt.Fprintf(&b, "(%s)\n", label)
+		}
+
+		line := inst.String()
+		if a, ok := inst.(code.AInstruction); ok {
+			value := int(a.Value)
+			if isJumpTarget(insts, address) {
+				if label, ok := labels[value]; ok {
+					line = "@" + label
+				}
+			} else if name, ok := variables[value]; ok {
+				// the assembler allocates variables in order of first use
+				if allocated[name] {
+					line = "@" + name
+				} else if value == nextVariable {
+					allocated[name] = true
+					nextVariable++
+					line = "@" + name
+				}
+			}
+		}
+
+		fmt.Fprintf(&b, "   %s\n", line)
+	}
+
+	if label, ok := labels[len(insts)]; ok {
+		fmt.Fprintf(&b, "(%s)\n", label)
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func isJumpTarget(insts []code.Instruction, address int) bool {
+	if address+1 >= len(insts) {
+		return false
+	}
+
+	next, ok := insts[address+1].(code.CInstruction)
+
+	return ok && next.Jump != ""
+}
+
+func labelNames(insts []code.Instruction, symbols []symbol.Entry) map[int]string {
+	known := make(map[int]string)
+	used := make(map[string]bool)
+	for _, entry := range symbols {
+		used[entry.Name] = true
+		if _, exist := known[entry.Address]; entry.Kind == symbol.LABEL && !exist {
+			known[entry.Address] = entry.Name
+		}
+	}
+
+	labels := make(map[int]string)
+	for address, inst := range insts {
+		a, ok := inst.(code.AInstruction)
+		if !ok || !isJumpTarget(insts, address) || int(a.Value) > len(insts) {
+			continue
+		}
+
+		target := int(a.Value)
+		if _, exist := labels[target]; exist {
+			continue
+		}
+
+		name, ok := known[target]
+		if !ok {
+			name = fmt.Sprintf("ADDR_%d", target)
+			for used[name] {
+				name += "_"
+			}
+			used[name] = true
+		}
+
+		labels[target] = name
+	}
+
+	return labels
+}
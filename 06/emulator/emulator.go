@@ -0,0 +1,275 @@
+package emulator
+
+import (
+	"assembler/code"
+	"fmt"
+)
+
+const (
+	ROMSize = code.ROMSize
+	RAMSize = 32768
+
+	SCREEN     = 16384
+	ScreenSize = 8192 // 256 rows of 32 words
+	KBD        = 24576
+)
+
+// instruction is a ROM word decoded once, when the program is loaded.
+type instruction struct {
+	err error
+
+	isA   bool
+	value uint16
+
+	useM    bool   // the y input of the ALU is M instead of A
+	alu     uint16 // zx nx zy ny f no
+	shift   string // mnemonic of an extended shift, "" for the ALU
+	operand byte   // register of the shift
+	dest    uint16
+	jump    uint16
+}
+
+// Computer is the Hack computer: a CPU with the A, D and PC registers, a
+// ROM holding the program and a RAM whose SCREEN and KBD parts are memory
+// mapped.
+type Computer struct {
+	ROM [ROMSize]uint16
+	RAM [RAMSize]uint16
+
+	A  uint16
+	D  uint16
+	PC uint16
+
+	// Cycles is the number of instructions executed since Reset.
+	Cycles int
+
+	// OnKeyRead is called when an instruction reads KBD, with the number of
+	// instructions executed before it and the key it read.
+	OnKeyRead func(cycle int, key uint16)
+
+	program []instruction
+	size    int // words of the loaded program
+}
+
+// New makes a Computer with program in ROM.
+func New(program []uint16) (*Computer, error) {
+	c := &Computer{}
+	if err := c.Load(program); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Load puts program in ROM, clears the rest of it and resets the CPU.
+// RAM is left as it is.
+func (c *Computer) Load(program []uint16) error {
+	if len(program) > ROMSize {
+		return fmt.Errorf("program is %d words, ROM holds %d", len(program), ROMSize)
+	}
+
+	c.ROM = [ROMSize]uint16{}
+	copy(c.ROM[:], program)
+
+	c.program = make([]instruction, ROMSize)
+	for address, word := range c.ROM {
+		c.program[address] = decode(word)
+	}
+	c.size = len(program)
+
+	c.Reset()
+
+	return nil
+}
+
+// Reset starts the program again, like the reset input of the CPU: PC and
+// the cycle count go back to 0 while the registers and RAM keep their values.
+func (c *Computer) Reset() {
+	c.PC = 0
+	c.Cycles = 0
+}
+
+// SetKey puts the Hack code of the key that is pressed in KBD, 0 for none.
+func (c *Computer) SetKey(key uint16) {
+	c.RAM[KBD] = key
+}
+
+// Halted reports whether the program has stopped: PC is past the end of the
+// program, or it is at an infinite loop like (END) @END 0;JMP, which jumps
+// back to itself without changing anything.
+func (c *Computer) Halted() bool {
+	if int(c.PC) >= c.size {
+		return true
+	}
+
+	inst := c.program[c.PC]
+	if inst.isA && int(c.PC)+1 < c.size {
+		next := c.program[c.PC+1]
+		return inst.value == c.PC && isUnconditional(next)
+	}
+
+	return !inst.isA && inst.err == nil && c.A == c.PC && isUnconditional(inst)
+}
+
+// isUnconditional reports whether inst is a jump that is always taken and
+// changes nothing but PC.
+func isUnconditional(inst instruction) bool {
+	return !inst.isA && inst.err == nil && inst.jump == 0b111 && inst.dest == 0
+}
+
+// Step executes the instruction at PC. An instruction that both sets A and
+// jumps, like A=M;JMP, jumps to the new value of A, as in the CPU emulator
+// of the course.
+func (c *Computer) Step() error {
+	inst := c.program[c.PC&(ROMSize-1)]
+	if inst.err != nil {
+		return fmt.Errorf("ROM[%d]: %v", c.PC, inst.err)
+	}
+
+	c.Cycles++
+
+	if inst.isA {
+		c.A = inst.value
+		c.PC++
+
+		return nil
+	}
+
+	address := c.A & (RAMSize - 1)
+	if inst.useM && address == KBD && c.OnKeyRead != nil {
+		c.OnKeyRead(c.Cycles-1, c.RAM[KBD])
+	}
+	out := c.compute(inst, address)
+
+	if inst.dest&0b001 != 0 && address != KBD {
+		c.RAM[address] = out
+	}
+	if inst.dest&0b100 != 0 {
+		c.A = out
+	}
+	if inst.dest&0b010 != 0 {
+		c.D = out
+	}
+
+	if jumps(inst.jump, int16(out)) {
+		c.PC = c.A
+	} else {
+		c.PC++
+	}
+
+	return nil
+}
+
+// Run executes instructions until the program halts or cycles instructions
+// were executed. There is no limit when cycles is 0.
+func (c *Computer) Run(cycles int) error {
+	for n := 0; cycles <= 0 || n < cycles; n++ {
+		if c.Halted() {
+			return nil
+		}
+
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Instruction returns the instruction at PC.
+func (c *Computer) Instruction() (code.Instruction, error) {
+	return code.Decode(c.ROM[c.PC&(ROMSize-1)])
+}
+
+func (c *Computer) compute(inst instruction, address uint16) uint16 {
+	y := c.A
+	if inst.useM {
+		y = c.RAM[address]
+	}
+
+	if inst.shift != "" {
+		var x uint16
+		switch inst.operand {
+		case 'A':
+			x = c.A
+		case 'D':
+			x = c.D
+		case 'M':
+			x = c.RAM[address]
+		}
+
+		if inst.shift[1:] == "<<" {
+			return x << 1
+		}
+
+		return uint16(int16(x) >> 1)
+	}
+
+	return alu(inst.alu, c.D, y)
+}
+
+// alu computes the output of the Hack ALU for the control bits
+// zx nx zy ny f no.
+func alu(bits uint16, x uint16, y uint16) uint16 {
+	if bits&0b100000 != 0 {
+		x = 0
+	}
+	if bits&0b010000 != 0 {
+		x = ^x
+	}
+	if bits&0b001000 != 0 {
+		y = 0
+	}
+	if bits&0b000100 != 0 {
+		y = ^y
+	}
+
+	var out uint16
+	if bits&0b000010 != 0 {
+		out = x + y
+	} else {
+		out = x & y
+	}
+	if bits&0b000001 != 0 {
+		out = ^out
+	}
+
+	return out
+}
+
+func jumps(jump uint16, out int16) bool {
+	return jump&0b100 != 0 && out < 0 ||
+		jump&0b010 != 0 && out == 0 ||
+		jump&0b001 != 0 && out > 0
+}
+
+// decode looks a word up in the code tables and keeps the bits the CPU
+// needs to execute it.
+func decode(word uint16) instruction {
+	inst, err := code.Decode(word)
+	if err != nil {
+		return instruction{err: err}
+	}
+
+	a, ok := inst.(code.AInstruction)
+	if ok {
+		return instruction{isA: true, value: a.Value}
+	}
+
+	c := inst.(code.CInstruction)
+	dest, _ := code.Dest(c.Dest)
+	jump, _ := code.Jump(c.Jump)
+
+	if code.IsExtended(c.Comp) {
+		return instruction{shift: c.Comp, operand: c.Comp[0], dest: dest, jump: jump}
+	}
+
+	comp, _ := code.Comp(c.Comp)
+
+	return instruction{
+		useM: comp&0b1000000 != 0,
+		alu:  comp & 0b111111,
+		dest: dest,
+		jump: jump,
+	}
+}
@@ -0,0 +1,121 @@
+package emulator
+
+import (
+	"assembler/parser"
+	"assembler/source"
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T, lines []source.Line) *Computer {
+	t.Helper()
+
+	words, _, err := parser.AssembleLines(lines, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(words)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func program(t *testing.T, src string) *Computer {
+	t.Helper()
+
+	var lines []source.Line
+	for i, line := range strings.Split(src, "\n") {
+		lines = append(lines, source.Line{Text: line, File: "test.asm", Line: i + 1})
+	}
+
+	return load(t, lines)
+}
+
+func sample(t *testing.T, name string) *Computer {
+	t.Helper()
+
+	lines, err := source.Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return load(t, lines)
+}
+
+func TestAdd(t *testing.T) {
+	c := sample(t, "../add/Add.asm")
+	if err := c.Run(1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.Halted() || c.RAM[0] != 5 {
+		t.Errorf("got RAM[0] = %d, halted %v, want 5 and halted", c.RAM[0], c.Halted())
+	}
+}
+
+func TestMax(t *testing.T) {
+	for _, name := range []string{"../max/Max.asm", "../max/MaxL.asm"} {
+		for _, test := range [][3]uint16{{3, 5, 5}, {5, 3, 5}, {0xFFFF, 2, 2}, {7, 7, 7}} {
+			c := sample(t, name)
+			c.RAM[0], c.RAM[1] = test[0], test[1]
+			if err := c.Run(1000); err != nil {
+				t.Fatal(err)
+			}
+
+			if !c.Halted() || c.RAM[2] != test[2] {
+				t.Errorf("%s of %d and %d: got %d, halted %v, want %d", name, int16(test[0]), int16(test[1]), c.RAM[2], c.Halted(), test[2])
+			}
+		}
+	}
+}
+
+func TestHalted(t *testing.T) {
+	tests := []struct {
+		src    string
+		cycles int
+		want   bool
+	}{
+		{"@1\nD=A\n(END)\n@END\n0;JMP", 2, true}, // at @END
+		{"@1\nD=A\n(END)\n@END\n0;JMP", 1, false},
+		{"@3\nD=A\nA=D\n0;JMP", 3, true}, // at 0;JMP with A = PC
+		{"@0\nD=A", 2, true},             // past the end
+		{"(LOOP)\n@LOOP\nD;JEQ\n@LOOP\n0;JMP", 4, false},
+		{"(LOOP)\nM=M+1\n@LOOP\n0;JMP", 3, false}, // changes RAM every time
+	}
+
+	for _, test := range tests {
+		c := program(t, test.src)
+		for i := 0; i < test.cycles; i++ {
+			if err := c.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := c.Halted(); got != test.want {
+			t.Errorf("%q after %d cycles: got halted %v, want %v", test.src, test.cycles, got, test.want)
+		}
+	}
+}
+
+func TestJumpToUpdatedA(t *testing.T) {
+	c := program(t, `
+   @6
+   D=A
+   @4
+   A=D;JMP // jumps to 6, not 4
+   @1
+   D=A
+   @R0
+   M=D`)
+
+	if err := c.Run(100); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.RAM[0] != 6 {
+		t.Errorf("got RAM[0] = %d, want 6", c.RAM[0])
+	}
+}
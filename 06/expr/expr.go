@@ -0,0 +1,419 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a parsed constant expression of an A-Instruction, such as
+// SCREEN+32*12, 0x4000 or 'A'.
+type Expr interface {
+	String() string
+}
+
+type Number struct {
+	Value int
+}
+
+func (n *Number) String() string {
+	return strconv.Itoa(n.Value)
+}
+
+type Symbol struct {
+	Name string
+}
+
+func (s *Symbol) String() string {
+	return s.Name
+}
+
+type Unary struct {
+	Op      byte
+	Operand Expr
+}
+
+func (u *Unary) String() string {
+	return string(u.Op) + u.Operand.String()
+}
+
+type Binary struct {
+	Op    byte
+	Left  Expr
+	Right Expr
+}
+
+func (b *Binary) String() string {
+	return "(" + b.Left.String() + string(b.Op) + b.Right.String() + ")"
+}
+
+// Error reports a problem at Offset bytes into the expression text.
+// Literal is set when the problem is a malformed number.
+type Error struct {
+	Offset  int
+	Msg     string
+	Literal string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Parse parses s. Operators, from lowest to highest precedence, are
+// |, &, + -, * / % and the unary - and !. Numbers may be decimal, hex (0x),
+// binary (0b) or a character literal.
+func Parse(s string) (Expr, error) {
+	p := &parser{src: s}
+
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+
+	return e, nil
+}
+
+// IsSymbol reports whether s is a plain Hack symbol: letters, digits, _, .,
+// $ and :, not starting with a digit.
+func IsSymbol(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !IsSymbolChar(s[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Eval computes e, asking lookup for the value of every symbol.
+func Eval(e Expr, lookup func(name string) (int, error)) (int, error) {
+	switch e := e.(type) {
+	case *Number:
+		return e.Value, nil
+	case *Symbol:
+		return lookup(e.Name)
+	case *Unary:
+		v, err := Eval(e.Operand, lookup)
+		if err != nil {
+			return 0, err
+		}
+
+		if e.Op == '-' {
+			return -v, nil
+		}
+
+		return ^v, nil
+	case *Binary:
+		l, err := Eval(e.Left, lookup)
+		if err != nil {
+			return 0, err
+		}
+
+		r, err := Eval(e.Right, lookup)
+		if err != nil {
+			return 0, err
+		}
+
+		return apply(e.Op, l, r)
+	}
+
+	return 0, fmt.Errorf("unknown expression %T", e)
+}
+
+// Term is the value Symbol+Offset, where Symbol is only known at link time.
+// Symbol is "" when the value is a constant.
+type Term struct {
+	Symbol string
+	Offset int
+}
+
+// EvalTerm computes e when the symbols for which lookup reports false are
+// not known yet. At most one such symbol may be used, and only added to or
+// subtracted from by constants, so that a linker can finish the computation.
+func EvalTerm(e Expr, lookup func(name string) (int, bool)) (Term, error) {
+	switch e := e.(type) {
+	case *Number:
+		return Term{Offset: e.Value}, nil
+	case *Symbol:
+		if v, ok := lookup(e.Name); ok {
+			return Term{Offset: v}, nil
+		}
+
+		return Term{Symbol: e.Name}, nil
+	case *Unary:
+		t, err := EvalTerm(e.Operand, lookup)
+		if err != nil {
+			return Term{}, err
+		}
+		if t.Symbol != "" {
+			return Term{}, &Error{Msg: fmt.Sprintf("%s can't be negated before it is linked", t.Symbol)}
+		}
+
+		if e.Op == '-' {
+			return Term{Offset: -t.Offset}, nil
+		}
+
+		return Term{Offset: ^t.Offset}, nil
+	case *Binary:
+		l, err := EvalTerm(e.Left, lookup)
+		if err != nil {
+			return Term{}, err
+		}
+
+		r, err := EvalTerm(e.Right, lookup)
+		if err != nil {
+			return Term{}, err
+		}
+
+		switch {
+		case l.Symbol != "" && r.Symbol != "":
+			return Term{}, &Error{Msg: fmt.Sprintf("%s and %s can't be combined before they are linked", l.Symbol, r.Symbol)}
+		case l.Symbol == "" && r.Symbol == "":
+			v, err := apply(e.Op, l.Offset, r.Offset)
+			if err != nil {
+				return Term{}, err
+			}
+
+			return Term{Offset: v}, nil
+		case e.Op == '+':
+			return Term{Symbol: l.Symbol + r.Symbol, Offset: l.Offset + r.Offset}, nil
+		case e.Op == '-' && l.Symbol != "":
+			return Term{Symbol: l.Symbol, Offset: l.Offset - r.Offset}, nil
+		}
+
+		return Term{}, &Error{Msg: fmt.Sprintf("%s can only be added to or subtracted from before it is linked", l.Symbol+r.Symbol)}
+	}
+
+	return Term{}, fmt.Errorf("unknown expression %T", e)
+}
+
+// Symbols returns the names used in e, in order of appearance.
+func Symbols(e Expr) []string {
+	switch e := e.(type) {
+	case *Symbol:
+		return []string{e.Name}
+	case *Unary:
+		return Symbols(e.Operand)
+	case *Binary:
+		return append(Symbols(e.Left), Symbols(e.Right)...)
+	}
+
+	return nil
+}
+
+func apply(op byte, l int, r int) (int, error) {
+	switch op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	case '/', '%':
+		if r == 0 {
+			return 0, &Error{Msg: "division by zero"}
+		}
+
+		if op == '/' {
+			return l / r, nil
+		}
+
+		return l % r, nil
+	case '&':
+		return l & r, nil
+	case '|':
+		return l | r, nil
+	}
+
+	return 0, fmt.Errorf("unknown operator %q", op)
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	return p.parseBinary("|", p.parseAnd)
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	return p.parseBinary("&", p.parseSum)
+}
+
+func (p *parser) parseSum() (Expr, error) {
+	return p.parseBinary("+-", p.parseProduct)
+}
+
+func (p *parser) parseProduct() (Expr, error) {
+	return p.parseBinary("*/%", p.parseUnary)
+}
+
+func (p *parser) parseBinary(ops string, next func() (Expr, error)) (Expr, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || !strings.ContainsRune(ops, rune(p.src[p.pos])) {
+			return left, nil
+		}
+
+		op := p.src[p.pos]
+		p.pos++
+
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &Binary{Op: op, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	p.skipSpace()
+	if p.pos < len(p.src) && (p.src[p.pos] == '-' || p.src[p.pos] == '!') {
+		op := p.src[p.pos]
+		p.pos++
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Unary{Op: op, Operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("expected a number or a symbol")
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+
+	switch {
+	case c == '(':
+		p.pos++
+
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+
+		return e, nil
+	case c == '\'':
+		return p.parseChar()
+	case isDigit(c):
+		for p.pos < len(p.src) && IsSymbolChar(p.src[p.pos]) {
+			p.pos++
+		}
+
+		literal := p.src[start:p.pos]
+		v, err := parseNumber(literal)
+		if err != nil {
+			return nil, &Error{Offset: start, Msg: fmt.Sprintf("invalid number %q", literal), Literal: literal}
+		}
+
+		return &Number{Value: v}, nil
+	case IsSymbolChar(c):
+		for p.pos < len(p.src) && IsSymbolChar(p.src[p.pos]) {
+			p.pos++
+		}
+
+		return &Symbol{Name: p.src[start:p.pos]}, nil
+	}
+
+	return nil, p.errorf("unexpected %q", string(c))
+}
+
+func (p *parser) parseChar() (Expr, error) {
+	start := p.pos
+	p.pos++ // opening quote
+
+	if p.pos >= len(p.src) {
+		p.pos = start
+		return nil, p.errorf("unterminated character literal")
+	}
+
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\\' && p.pos < len(p.src) {
+		escaped, ok := map[byte]byte{'n': '\n', 't': '\t', '0': 0, '\\': '\\', '\'': '\''}[p.src[p.pos]]
+		if !ok {
+			p.pos--
+			return nil, p.errorf("unknown escape \\%c", p.src[p.pos+1])
+		}
+		c = escaped
+		p.pos++
+	}
+
+	if p.pos >= len(p.src) || p.src[p.pos] != '\'' {
+		p.pos = start
+		return nil, p.errorf("unterminated character literal")
+	}
+	p.pos++
+
+	return &Number{Value: int(c)}, nil
+}
+
+func parseNumber(literal string) (int, error) {
+	lower := strings.ToLower(literal)
+
+	var (
+		v   int64
+		err error
+	)
+	switch {
+	case strings.HasPrefix(lower, "0x"):
+		v, err = strconv.ParseInt(lower[2:], 16, 32)
+	case strings.HasPrefix(lower, "0b"):
+		v, err = strconv.ParseInt(lower[2:], 2, 32)
+	default:
+		v, err = strconv.ParseInt(literal, 10, 32)
+	}
+
+	return int(v), err
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// IsSymbolChar reports whether c can appear in a Hack symbol.
+func IsSymbolChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || isDigit(c) ||
+		c == '_' || c == '.' || c == '$' || c == ':'
+}
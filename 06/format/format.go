@@ -0,0 +1,154 @@
+package format
+
+import (
+	"assembler/code"
+	"assembler/source"
+	"strings"
+)
+
+// Indent is put before every instruction, as in the bundled samples.
+const Indent = "   "
+
+type line struct {
+	indent  string
+	code    string
+	comment string // from // on, "" when there is none
+	blank   bool
+}
+
+// Lines formats Hack assembly. Labels and directives start at column 0 and
+// instructions are indented under them. C-Instructions are written in the
+// spelling of the code tables, so D = M + 1 becomes D=M+1 and A+D becomes
+// D+A, unless they are not valid and are left alone. Comments are kept:
+// trailing comments of consecutive lines are aligned, and comments on a line
+// of their own stay at column 0 when they were written there and are
+// indented like the next line otherwise. Runs of blank lines become one, and
+// blank lines at the end are dropped. Formatting formatted lines changes
+// nothing.
+func Lines(lines []source.Line) []string {
+	formatted := make([]line, 0, len(lines))
+	for _, l := range lines {
+		text := strings.TrimRight(l.Text, " \t")
+
+		if text == "" {
+			if len(formatted) > 0 && !formatted[len(formatted)-1].blank {
+				formatted = append(formatted, line{blank: true})
+			}
+
+			continue
+		}
+
+		c := strings.TrimSpace(source.Code(text))
+		f := line{code: formatCommand(c), comment: text[len(source.Code(text)):]}
+		if c == "" && text[0] != ' ' && text[0] != '\t' {
+			f.indent = "-" // stays at column 0
+		}
+		formatted = append(formatted, f)
+	}
+	for len(formatted) > 0 && formatted[len(formatted)-1].blank {
+		formatted = formatted[:len(formatted)-1]
+	}
+
+	indentComments(formatted)
+
+	return render(formatted)
+}
+
+func formatCommand(command string) string {
+	switch {
+	case command == "":
+		return ""
+	case command[0] == '@':
+		return "@" + strings.TrimSpace(command[1:])
+	case command[0] == '(' || command[0] == '.':
+		return command
+	}
+
+	return formatCInstruction(command)
+}
+
+// formatCInstruction returns the canonical spelling of a C-Instruction, or
+// command itself when it is not one, like a macro call.
+func formatCInstruction(command string) string {
+	var dest, comp, jump string
+
+	rest := command
+	if i := strings.Index(rest, "="); i >= 0 {
+		dest = rest[:i]
+		rest = rest[i+1:]
+	}
+	if i := strings.Index(rest, ";"); i >= 0 {
+		comp = rest[:i]
+		jump = rest[i+1:]
+	} else {
+		comp = rest
+	}
+
+	dest, okDest := code.NormalizeDest(dest)
+	comp, okComp := code.NormalizeComp(comp)
+	jump, okJump := code.NormalizeJump(jump)
+	if !okDest || !okComp || !okJump {
+		return command
+	}
+
+	return code.CInstruction{Dest: dest, Comp: comp, Jump: jump}.String()
+}
+
+func isInstruction(command string) bool {
+	return command != "" && command[0] != '(' && command[0] != '.'
+}
+
+// indentComments indents comments on a line of their own like the next
+// line with code.
+func indentComments(lines []line) {
+	indent := ""
+	for i := len(lines) - 1; i >= 0; i-- {
+		l := &lines[i]
+		switch {
+		case l.blank:
+		case l.code != "":
+			indent = ""
+			if isInstruction(l.code) {
+				indent = Indent
+			}
+			l.indent = indent
+		case l.indent == "-":
+			l.indent = ""
+		default:
+			l.indent = indent
+		}
+	}
+}
+
+// render aligns the trailing comments of each run of lines with code.
+func render(lines []line) []string {
+	out := make([]string, len(lines))
+
+	for start := 0; start < len(lines); {
+		end := start
+		width := 0
+		for ; end < len(lines) && lines[end].code != ""; end++ {
+			if w := len(lines[end].indent) + len(lines[end].code); lines[end].comment != "" && w > width {
+				width = w
+			}
+		}
+
+		for i := start; i < end; i++ {
+			l := lines[i]
+			text := l.indent + l.code
+			if l.comment != "" {
+				text += strings.Repeat(" ", width-len(text)+2) + l.comment
+			}
+			out[i] = text
+		}
+
+		if end == start {
+			l := lines[end]
+			out[end] = l.indent + l.comment
+			end++
+		}
+		start = end
+	}
+
+	return out
+}
@@ -0,0 +1,26 @@
+package keyboard
+
+// Hack codes of the keys that are not printable characters. Printable
+// characters are their ASCII codes.
+const (
+	NEWLINE   = uint16(128)
+	BACKSPACE = uint16(129)
+	LEFT      = uint16(130)
+	UP        = uint16(131)
+	RIGHT     = uint16(132)
+	DOWN      = uint16(133)
+	HOME      = uint16(134)
+	END       = uint16(135)
+	PAGE_UP   = uint16(136)
+	PAGE_DOWN = uint16(137)
+	INSERT    = uint16(138)
+	DELETE    = uint16(139)
+	ESC       = uint16(140)
+	F1        = uint16(141) // F2 to F12 follow up to 152
+)
+
+// Printable reports whether c is a character with the same Hack code as
+// its ASCII code.
+func Printable(c byte) bool {
+	return c >= ' ' && c <= '~'
+}
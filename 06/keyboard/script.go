@@ -0,0 +1,317 @@
+package keyboard
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default times of a typed key, in cycles: long enough for the loops of
+// Keyboard.readChar to see the key go down and up again.
+const (
+	DefaultDown = 20000
+	DefaultUp   = 20000
+)
+
+// names are the names of keys a script can hold, besides numbers and
+// quoted characters.
+var names = map[string]uint16{
+	"NEWLINE":   NEWLINE,
+	"BACKSPACE": BACKSPACE,
+	"LEFT":      LEFT,
+	"UP":        UP,
+	"RIGHT":     RIGHT,
+	"DOWN":      DOWN,
+	"HOME":      HOME,
+	"END":       END,
+	"PAGE_UP":   PAGE_UP,
+	"PAGE_DOWN": PAGE_DOWN,
+	"INSERT":    INSERT,
+	"DELETE":    DELETE,
+	"ESC":       ESC,
+	"SPACE":     ' ',
+}
+
+func init() {
+	for i := uint16(0); i < 12; i++ {
+		names["F"+strconv.Itoa(int(i)+1)] = F1 + i
+	}
+}
+
+// Name returns the name of key: the character when it is printable, and
+// the name a script uses for it otherwise. No key is none.
+func Name(key uint16) string {
+	if key == 0 {
+		return "none"
+	}
+	if key < 128 && Printable(byte(key)) && key != ' ' {
+		return string(rune(key))
+	}
+
+	for name, code := range names {
+		if code == key {
+			return name
+		}
+	}
+
+	return strconv.Itoa(int(key))
+}
+
+// Press is a key that is down from cycle From until cycle To, To not
+// included. Cycles count the instructions executed before.
+type Press struct {
+	Key  uint16
+	From int
+	To   int
+}
+
+// Script is the keyboard input of a run, as presses sorted by cycle that
+// don't overlap.
+type Script struct {
+	Presses []Press
+}
+
+// ParseScript reads an input script. Every line is one of
+//
+//	hold KEY FROM TO         // KEY is down from cycle FROM to cycle TO
+//	type AT "TEXT" [DOWN UP] // TEXT is typed from cycle AT on
+//
+// where KEY is a Hack code, a name like LEFT or F1, or a quoted character.
+// Every character of TEXT is down for DOWN cycles and then up for UP
+// cycles; \n is NEWLINE and \b is BACKSPACE. Comments are written //.
+func ParseScript(src string) (*Script, error) {
+	s := &Script{}
+
+	for i, line := range strings.Split(src, "\n") {
+		fields, err := split(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		var presses []Press
+		switch fields[0] {
+		case "hold":
+			presses, err = parseHold(fields[1:])
+		case "type":
+			presses, err = parseType(fields[1:])
+		default:
+			err = fmt.Errorf("unknown command %q", fields[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		s.Presses = append(s.Presses, presses...)
+	}
+
+	sort.SliceStable(s.Presses, func(i, j int) bool {
+		return s.Presses[i].From < s.Presses[j].From
+	})
+	for i := 1; i < len(s.Presses); i++ {
+		if prev, p := s.Presses[i-1], s.Presses[i]; p.From < prev.To {
+			return nil, fmt.Errorf("%s at cycle %d overlaps %s held until cycle %d", Name(p.Key), p.From, Name(prev.Key), prev.To)
+		}
+	}
+
+	return s, nil
+}
+
+func parseHold(args []string) ([]Press, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("hold needs a key and the cycles it is held from and to")
+	}
+
+	key, err := parseKey(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	cycles, err := parseCycles(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if cycles[1] <= cycles[0] {
+		return nil, fmt.Errorf("hold must end after cycle %d", cycles[0])
+	}
+
+	return []Press{{Key: key, From: cycles[0], To: cycles[1]}}, nil
+}
+
+func parseType(args []string) ([]Press, error) {
+	if len(args) != 2 && len(args) != 4 {
+		return nil, fmt.Errorf("type needs a cycle and a quoted text, and can have the cycles of key down and key up")
+	}
+
+	cycles, err := parseCycles(append([]string{args[0]}, args[2:]...))
+	if err != nil {
+		return nil, err
+	}
+	at, down, up := cycles[0], DefaultDown, DefaultUp
+	if len(cycles) == 3 {
+		down, up = cycles[1], cycles[2]
+	}
+	if down == 0 || up == 0 {
+		return nil, fmt.Errorf("keys must be down and up for at least 1 cycle each, or a repeated character is one long press")
+	}
+
+	text, err := unquote(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var presses []Press
+	for _, r := range text {
+		key, err := charKey(r)
+		if err != nil {
+			return nil, err
+		}
+
+		presses = append(presses, Press{Key: key, From: at, To: at + down})
+		at += down + up
+	}
+
+	return presses, nil
+}
+
+func parseCycles(args []string) ([]int, error) {
+	cycles := make([]int, len(args))
+	for i, arg := range args {
+		cycle, err := strconv.Atoi(arg)
+		if err != nil || cycle < 0 {
+			return nil, fmt.Errorf("%q is not a cycle", arg)
+		}
+		cycles[i] = cycle
+	}
+
+	return cycles, nil
+}
+
+func parseKey(s string) (uint16, error) {
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		text, err := unquote(s)
+		if err != nil {
+			return 0, err
+		}
+		if len([]rune(text)) != 1 {
+			return 0, fmt.Errorf("%s is not one character", s)
+		}
+
+		return charKey([]rune(text)[0])
+	}
+
+	if key, ok := names[strings.ToUpper(s)]; ok {
+		return key, nil
+	}
+
+	key, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || key == 0 {
+		return 0, fmt.Errorf("%q is not a key", s)
+	}
+
+	return uint16(key), nil
+}
+
+// charKey returns the Hack code of a character of typed text.
+func charKey(r rune) (uint16, error) {
+	switch {
+	case r == '\n':
+		return NEWLINE, nil
+	case r == '\b':
+		return BACKSPACE, nil
+	case r == 0x1b:
+		return ESC, nil
+	case r < 128 && Printable(byte(r)):
+		return uint16(r), nil
+	}
+
+	return 0, fmt.Errorf("%q can't be typed on the Hack keyboard", r)
+}
+
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") && len(s) >= 2 {
+		s = "\"" + s[1:len(s)-1] + "\""
+	}
+
+	text, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a quoted text", s)
+	}
+
+	return text, nil
+}
+
+// split splits a line into fields, keeping quoted text with its spaces in
+// one field and dropping the comment.
+func split(line string) ([]string, error) {
+	var fields []string
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(line[i:], "//"):
+			return fields, nil
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			fields = append(fields, line[i:end+1])
+			i = end + 1
+		default:
+			start := i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' && line[i] != '\r' && !strings.HasPrefix(line[i:], "//") {
+				i++
+			}
+			fields = append(fields, line[start:i])
+		}
+	}
+
+	return fields, nil
+}
+
+// Key returns the key that is down at cycle, 0 for none.
+func (s *Script) Key(cycle int) uint16 {
+	i := sort.Search(len(s.Presses), func(i int) bool {
+		return s.Presses[i].To > cycle
+	})
+	if i < len(s.Presses) && s.Presses[i].From <= cycle {
+		return s.Presses[i].Key
+	}
+
+	return 0
+}
+
+// Next returns the first cycle after cycle at which the key changes, or -1
+// when it doesn't change anymore.
+func (s *Script) Next(cycle int) int {
+	i := sort.Search(len(s.Presses), func(i int) bool {
+		return s.Presses[i].To > cycle
+	})
+	if i == len(s.Presses) {
+		return -1
+	}
+
+	if p := s.Presses[i]; p.From > cycle {
+		return p.From
+	}
+
+	return s.Presses[i].To
+}
+
+// Read is a value a program read from KBD, recorded when it differs from
+// the value read before.
+type Read struct {
+	Cycle int
+	Key   uint16
+}
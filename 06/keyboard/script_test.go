@@ -0,0 +1,33 @@
+package keyboard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTypeRepeatedCharacter(t *testing.T) {
+	s, err := ParseScript(`type 10 "aa" 3 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Press{{Key: 'a', From: 10, To: 13}, {Key: 'a', From: 15, To: 18}}
+	if !reflect.DeepEqual(s.Presses, want) {
+		t.Errorf("got %v, want %v", s.Presses, want)
+	}
+
+	// the key is up between the two presses
+	if key := s.Key(14); key != 0 {
+		t.Errorf("got key %d at cycle 14, want none", key)
+	}
+}
+
+func TestTypeNeedsUpCycles(t *testing.T) {
+	for _, src := range []string{`type 0 "aa" 3 0`, `type 0 "ab" 0 3`} {
+		_, err := ParseScript(src)
+		if err == nil || !strings.Contains(err.Error(), "at least 1 cycle") {
+			t.Errorf("%s: got %v, want an error that keys must be down and up", src, err)
+		}
+	}
+}
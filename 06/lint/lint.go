@@ -0,0 +1,242 @@
+package lint
+
+import (
+	"assembler/code"
+	"assembler/expr"
+	"assembler/parser"
+	"assembler/symbol"
+	"fmt"
+	"strings"
+)
+
+// Check names one kind of problem the linter looks for.
+type Check string
+
+const (
+	A_ASSIGNMENT    = Check("a-assignment")
+	MISSING_AT      = Check("missing-at")
+	UNUSED_LABEL    = Check("unused-label")
+	SINGLE_VARIABLE = Check("single-variable")
+	UNREACHABLE     = Check("unreachable")
+)
+
+// Problem is a line the assembler accepts but that is probably a mistake.
+type Problem struct {
+	Check Check
+	Msg   string
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s [%s]", p.Msg, p.Check)
+}
+
+// where A came from at some point of the program
+type aSource int
+
+const (
+	aUnknown  aSource = iota // after a label, any jump may have set it
+	aNone                    // nothing set it since the program started
+	aLoaded                  // an A-Instruction
+	aComputed                // a C-Instruction with A in its dest
+)
+
+type linter struct {
+	symbols *symbol.SymbolTable
+	refs    map[string]int
+	targets map[int]bool
+	result  parser.ErrorList
+}
+
+// Lint looks for common mistakes in a program returned by parser.Parse:
+//
+//   - a C-Instruction that reads M or jumps right after one that sets A to
+//     0, 1 or -1, where @0, @1 or @-1 was probably meant. A set to D or M is
+//     how pointers are followed, so only these constants are reported
+//   - a jump with no A-Instruction before it, either at the start of the
+//     program or right after a label, or one after an A-Instruction that
+//     was used to access memory
+//   - labels that are never used
+//   - variables that are used only once, which is often a typo
+//   - instructions after an unconditional jump that no label or jump leads
+//     to
+//
+// The problems are returned as warnings, in source order.
+func Lint(program []parser.Statement, symbols *symbol.SymbolTable) parser.ErrorList {
+	l := &linter{symbols: symbols, refs: references(program), targets: jumpTargets(program)}
+
+	var (
+		prev     *parser.Statement // previous instruction, nil after a label
+		from     = aNone
+		load     *parser.Statement // A-Instruction that set A when from is aLoaded
+		memory   bool              // M was used since A was set
+		dead     bool
+		reported bool
+	)
+	for i := range program {
+		s := &program[i]
+
+		if l.targets[s.Address] {
+			dead = false
+		}
+
+		if dead && !reported && s.CommandType != parser.L_COMMAND {
+			l.report(s, 0, UNREACHABLE, "no jump can reach this instruction")
+			reported = true
+		}
+
+		switch s.CommandType {
+		case parser.L_COMMAND:
+			if l.refs[s.Symbol] == 0 {
+				l.report(s, 0, UNUSED_LABEL, "label %s is never used", s.Symbol)
+			} else {
+				dead = false
+			}
+
+			prev = nil
+			from = aUnknown
+
+			continue
+		case parser.A_COMMAND:
+			l.checkVariables(s)
+
+			from = aLoaded
+			load = s
+			memory = false
+		case parser.C_COMMAND:
+			c := s.Instruction.(code.CInstruction)
+			memory = memory || usesM(c)
+
+			if prev != nil {
+				l.checkAssignment(prev, s, c)
+			}
+
+			if c.Jump != "" {
+				switch {
+				case from == aNone:
+					l.report(s, 0, MISSING_AT, "jump with no A-Instruction before it")
+				case from == aUnknown && prev == nil:
+					l.report(s, 0, MISSING_AT, "jump right after a label, with no A-Instruction before it")
+				case from == aLoaded && memory && !l.isLabel(load.Symbol):
+					l.report(s, 0, MISSING_AT, "jump to @%s, which was loaded to access memory", load.Symbol)
+				}
+			}
+
+			if strings.Contains(c.Dest, "A") {
+				from = aComputed
+			}
+		}
+
+		if c, ok := s.Instruction.(code.CInstruction); ok && c.Jump == "JMP" {
+			dead = true
+			reported = false
+		}
+
+		prev = s
+	}
+
+	return l.result
+}
+
+// checkAssignment reports a C-Instruction that uses A as an address right
+// after the one before it set A to a constant.
+func (l *linter) checkAssignment(prev *parser.Statement, s *parser.Statement, c code.CInstruction) {
+	p, ok := prev.Instruction.(code.CInstruction)
+	if !ok || !strings.Contains(p.Dest, "A") {
+		return
+	}
+
+	if p.Comp != "0" && p.Comp != "1" && p.Comp != "-1" {
+		return
+	}
+
+	if strings.Contains(c.Comp, "M") || c.Jump != "" {
+		l.report(s, 0, A_ASSIGNMENT, "uses A right after %q set it to %s, was @%s meant?", prev.Text, p.Comp, p.Comp)
+	}
+}
+
+// checkVariables reports the variables of an A-Instruction that are used
+// nowhere else.
+func (l *linter) checkVariables(s *parser.Statement) {
+	e, err := expr.Parse(s.Symbol)
+	if err != nil {
+		return
+	}
+
+	for _, name := range expr.Symbols(e) {
+		if kind, _ := l.symbols.Kind(name); kind == symbol.VARIABLE && l.refs[name] == 1 {
+			l.report(s, strings.Index(s.Text, name), SINGLE_VARIABLE, "variable %s is used only once", name)
+		}
+	}
+}
+
+func (l *linter) isLabel(name string) bool {
+	kind, _ := l.symbols.Kind(name)
+
+	return kind == symbol.LABEL
+}
+
+func (l *linter) report(s *parser.Statement, offset int, check Check, format string, args ...interface{}) {
+	pos := s.Pos
+	if offset > 0 {
+		pos.Column += offset
+	}
+
+	l.result = append(l.result, &parser.Error{
+		Pos:     pos,
+		Text:    s.Text,
+		Err:     &Problem{Check: check, Msg: fmt.Sprintf(format, args...)},
+		Warning: true,
+	})
+}
+
+// references counts the A-Instructions that use each symbol.
+func references(program []parser.Statement) map[string]int {
+	refs := make(map[string]int)
+	for _, s := range program {
+		if s.CommandType != parser.A_COMMAND {
+			continue
+		}
+
+		e, err := expr.Parse(s.Symbol)
+		if err != nil {
+			continue
+		}
+
+		for _, name := range expr.Symbols(e) {
+			refs[name]++
+		}
+	}
+
+	return refs
+}
+
+// jumpTargets returns the constant addresses that may be jumped to, so that
+// code reached as in @12 0;JMP is not reported as unreachable. Constants
+// read as values, as in @12 D=A, are included too, because that is how
+// return addresses are saved.
+func jumpTargets(program []parser.Statement) map[int]bool {
+	targets := make(map[int]bool)
+
+	var a *code.AInstruction
+	for _, s := range program {
+		switch inst := s.Instruction.(type) {
+		case code.AInstruction:
+			a = &inst
+		case code.CInstruction:
+			if a != nil && (inst.Jump != "" || strings.Contains(inst.Comp, "A")) {
+				targets[int(a.Value)] = true
+			}
+			if strings.Contains(inst.Dest, "A") {
+				a = nil
+			}
+		default:
+			a = nil
+		}
+	}
+
+	return targets
+}
+
+func usesM(c code.CInstruction) bool {
+	return strings.Contains(c.Comp, "M") || strings.Contains(c.Dest, "M")
+}
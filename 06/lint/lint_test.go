@@ -0,0 +1,72 @@
+package lint
+
+import (
+	"assembler/parser"
+	"assembler/source"
+	"strings"
+	"testing"
+)
+
+func lint(t *testing.T, src string) []Check {
+	t.Helper()
+
+	var lines []source.Line
+	for i, line := range strings.Split(src, "\n") {
+		lines = append(lines, source.Line{Text: line, File: "test.asm", Line: i + 1})
+	}
+
+	program, symbols, err := parser.Parse(lines, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var checks []Check
+	for _, e := range Lint(program, symbols) {
+		checks = append(checks, e.Err.(*Problem).Check)
+	}
+
+	return checks
+}
+
+func has(checks []Check, check Check) bool {
+	for _, c := range checks {
+		if c == check {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		check Check
+		src   string
+		want  bool
+	}{
+		{A_ASSIGNMENT, "A=1\nD=M\n(END)\n@END\n0;JMP", true},
+		{A_ASSIGNMENT, "@1\nD=M\n(END)\n@END\n0;JMP", false},
+		{A_ASSIGNMENT, "@R0\nA=M\nD=M\n(END)\n@END\n0;JMP", false}, // a pointer, not a constant
+
+		{MISSING_AT, "D;JGT\n(END)\n@END\n0;JMP", true},
+		{MISSING_AT, "@x\nD=M\nD;JGT\n(END)\n@END\n0;JMP", true},
+		{MISSING_AT, "(LOOP)\nD;JGT\n@LOOP\n0;JMP", true},
+		{MISSING_AT, "(LOOP)\n@LOOP\nD;JGT\n@LOOP\n0;JMP", false},
+
+		{UNUSED_LABEL, "(UNUSED)\n(END)\n@END\n0;JMP", true},
+		{UNUSED_LABEL, "(END)\n@END\n0;JMP", false},
+
+		{SINGLE_VARIABLE, "@x\nM=1\n(END)\n@END\n0;JMP", true},
+		{SINGLE_VARIABLE, "@x\nM=1\n@x\nD=M\n(END)\n@END\n0;JMP", false},
+
+		{UNREACHABLE, "(END)\n@END\n0;JMP\n@0\nD=A", true},
+		{UNREACHABLE, "@SKIP\n0;JMP\n(SKIP)\n@0\nD=A\n(END)\n@END\n0;JMP", false},
+		{UNREACHABLE, "@4\n0;JMP\n(END)\n@END\n0;JMP\nD=0", false}, // @4 reaches it
+	}
+
+	for _, test := range tests {
+		if got := has(lint(t, test.src), test.check); got != test.want {
+			t.Errorf("%s in %q: got %v, want %v", test.check, test.src, got, test.want)
+		}
+	}
+}
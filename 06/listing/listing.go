@@ -0,0 +1,57 @@
+package listing
+
+import (
+	"assembler/source"
+	"assembler/symbol"
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Entry is one source line of a listing. Word is only set for instructions.
+type Entry struct {
+	Line          source.Line
+	Address       int
+	IsInstruction bool
+	Word          uint16
+}
+
+// Write writes a listing: every source line next to the ROM address, the
+// binary and the hexadecimal encoding of its instruction, followed by the
+// labels and the variables of st.
+func Write(w io.Writer, entries []Entry, st *symbol.SymbolTable) error {
+	writer := bufio.NewWriter(w)
+
+	fmt.Fprintf(writer, "%-5s  %-16s  %-4s  %5s  %s\n", "ROM", "BINARY", "HEX", "LINE", "SOURCE")
+
+	file := ""
+	for i, entry := range entries {
+		if i == 0 || entry.Line.File != file {
+			file = entry.Line.File
+			if file != "" {
+				fmt.Fprintf(writer, "%-5s  %-16s  %-4s  %5s  // file %s\n", "", "", "", "", file)
+			}
+		}
+
+		if entry.IsInstruction {
+			fmt.Fprintf(writer, "%05d  %016b  %04X  %5d  %s\n", entry.Address, entry.Word, entry.Word, entry.Line.Line, entry.Line.Text)
+		} else {
+			fmt.Fprintf(writer, "%-5s  %-16s  %-4s  %5d  %s\n", "", "", "", entry.Line.Line, entry.Line.Text)
+		}
+	}
+
+	writeSymbols(writer, "LABELS", "ROM", st, symbol.LABEL)
+	writeSymbols(writer, "VARIABLES", "RAM", st, symbol.VARIABLE)
+
+	return writer.Flush()
+}
+
+func writeSymbols(w io.Writer, title string, memory string, st *symbol.SymbolTable, kind symbol.Kind) {
+	fmt.Fprintf(w, "\n%s\n%-5s  %s\n", title, memory, "NAME")
+
+	for _, entry := range st.Entries() {
+		if entry.Kind == kind {
+			fmt.Fprintf(w, "%05d  %s\n", entry.Address, entry.Name)
+		}
+	}
+}
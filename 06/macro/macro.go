@@ -0,0 +1,259 @@
+package macro
+
+import (
+	"assembler/expr"
+	"assembler/source"
+	"strconv"
+	"strings"
+)
+
+// maxDepth limits how deeply macros can expand each other, and maxLines
+// the lines all expansions produce, so that macros that each invoke the
+// next one several times can't grow the program without end.
+const (
+	maxDepth = 64
+	maxLines = 1 << 18
+)
+
+type macro struct {
+	name   string
+	params []string
+	body   []source.Line
+	labels map[string]bool
+}
+
+type expander struct {
+	macros     map[string]*macro
+	expansions int
+	active     map[string]bool // macros being expanded
+	tooLong    bool            // maxLines was reached
+
+	out  []source.Line
+	errs source.Errors
+}
+
+// Expand runs the macro preprocessor over lines. A macro is defined with
+//
+//	.macro NAME a, b
+//	   ...
+//	.endm
+//
+// and used by writing its name followed by its arguments. Parameters are
+// replaced by the arguments wherever they appear as a whole symbol, and
+// every label declared inside the body gets a new name on each expansion.
+// Expanded lines keep the position of the line in the macro body.
+func Expand(lines []source.Line) ([]source.Line, error) {
+	if !source.HasDirective(lines, ".macro", ".endm") {
+		return lines, nil
+	}
+
+	e := &expander{
+		macros: make(map[string]*macro),
+		active: make(map[string]bool),
+		out:    make([]source.Line, 0, len(lines)),
+	}
+
+	var current *macro
+	var currentLine source.Line
+	for _, line := range lines {
+		code := source.Code(line.Text)
+		word := source.FirstWord(code)
+
+		if word == ".macro" {
+			if current != nil {
+				e.errorf(line, ".macro", "macro %s can't be defined inside macro %s", source.FirstWord(strings.TrimPrefix(strings.TrimSpace(code), ".macro")), current.name)
+				continue
+			}
+
+			current = e.define(line, code)
+			currentLine = line
+			continue
+		}
+
+		if word == ".endm" {
+			if current == nil {
+				e.errorf(line, ".endm", ".endm without .macro")
+				continue
+			}
+
+			if current.name != "" {
+				e.macros[current.name] = current
+			}
+			current = nil
+			continue
+		}
+
+		if current != nil {
+			current.body = append(current.body, line)
+			if label, ok := labelOf(code); ok {
+				current.labels[label] = true
+			}
+			continue
+		}
+
+		e.emit(line, 0)
+	}
+
+	if current != nil {
+		e.errorf(currentLine, ".macro", "macro %s has no .endm", current.name)
+	}
+
+	if len(e.errs) > 0 {
+		return nil, e.errs
+	}
+
+	return e.out, nil
+}
+
+func (e *expander) define(line source.Line, code string) *macro {
+	m := &macro{labels: make(map[string]bool)}
+
+	fields := strings.Fields(code)
+	if len(fields) < 2 {
+		e.errorf(line, ".macro", ".macro needs a name")
+		return m
+	}
+
+	name := fields[1]
+	if !expr.IsSymbol(name) {
+		e.errorf(line, name, "invalid macro name %q", name)
+		return m
+	}
+	if _, exist := e.macros[name]; exist {
+		e.errorf(line, name, "macro %s is already defined", name)
+		return m
+	}
+
+	rest := strings.TrimSpace(code)
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, ".macro"))
+	params := splitArgs(strings.TrimSpace(strings.TrimPrefix(rest, name)))
+
+	seen := make(map[string]bool)
+	for _, param := range params {
+		if !expr.IsSymbol(param) {
+			e.errorf(line, param, "invalid parameter name %q", param)
+		} else if seen[param] {
+			e.errorf(line, param, "duplicate parameter %q", param)
+		}
+		seen[param] = true
+	}
+
+	m.name = name
+	m.params = params
+
+	return m
+}
+
+// emit appends line to the output, expanding it first when it invokes a macro.
+func (e *expander) emit(line source.Line, depth int) {
+	if e.tooLong {
+		return
+	}
+
+	code := source.Code(line.Text)
+	m, ok := e.macros[source.FirstWord(code)]
+	if !ok {
+		if len(e.out) >= maxLines {
+			e.errorf(line, "", "macros expand to more than %d lines", maxLines)
+			e.tooLong = true
+			return
+		}
+
+		e.out = append(e.out, line)
+		return
+	}
+
+	if e.active[m.name] {
+		e.errorf(line, m.name, "macro %s invokes itself", m.name)
+		return
+	}
+	if depth >= maxDepth {
+		e.errorf(line, m.name, "macro %s expands more than %d levels deep", m.name, maxDepth)
+		return
+	}
+
+	args := splitArgs(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(code), m.name)))
+	if len(args) != len(m.params) {
+		e.errorf(line, m.name, "macro %s takes %d arguments, got %d", m.name, len(m.params), len(args))
+		return
+	}
+
+	e.expansions++
+	replacements := make(map[string]string, len(m.params)+len(m.labels))
+	for label := range m.labels {
+		replacements[label] = m.name + "$" + label + "$" + strconv.Itoa(e.expansions)
+	}
+	for i, param := range m.params {
+		replacements[param] = args[i]
+	}
+
+	e.active[m.name] = true
+	for _, bodyLine := range m.body {
+		expanded := bodyLine
+		expanded.Text = substitute(bodyLine.Text, replacements)
+
+		e.emit(expanded, depth+1)
+	}
+	e.active[m.name] = false
+}
+
+func (e *expander) errorf(line source.Line, text string, format string, args ...interface{}) {
+	e.errs = append(e.errs, source.Errorf(line, text, format, args...))
+}
+
+// substitute replaces every whole symbol in the code part of text that has
+// an entry in replacements. Comments are left alone.
+func substitute(text string, replacements map[string]string) string {
+	code := text
+	comment := ""
+	if i := strings.Index(text, "//"); i >= 0 {
+		code = text[:i]
+		comment = text[i:]
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(code); {
+		if !expr.IsSymbolChar(code[i]) {
+			b.WriteByte(code[i])
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(code) && expr.IsSymbolChar(code[j]) {
+			j++
+		}
+
+		word := code[i:j]
+		if replacement, ok := replacements[word]; ok {
+			word = replacement
+		}
+		b.WriteString(word)
+
+		i = j
+	}
+
+	return b.String() + comment
+}
+
+func splitArgs(s string) []string {
+	if strings.Contains(s, ",") {
+		args := strings.Split(s, ",")
+		for i := range args {
+			args[i] = strings.TrimSpace(args[i])
+		}
+
+		return args
+	}
+
+	return strings.Fields(s)
+}
+
+func labelOf(code string) (string, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) > 2 && code[0] == '(' && code[len(code)-1] == ')' {
+		return code[1 : len(code)-1], true
+	}
+
+	return "", false
+}
@@ -0,0 +1,129 @@
+package macro
+
+import (
+	"assembler/source"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func lines(text string) []source.Line {
+	var result []source.Line
+	for i, line := range strings.Split(text, "\n") {
+		result = append(result, source.Line{Text: line, File: "test.asm", Line: i + 1})
+	}
+
+	return result
+}
+
+func texts(lines []source.Line) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = strings.TrimSpace(line.Text)
+	}
+
+	return result
+}
+
+func TestSubstitution(t *testing.T) {
+	src := `.macro MOVE from, to
+   @from
+   D=M // from stays in comments
+   @to
+   M=D
+.endm
+MOVE R0, R1
+MOVE x y`
+
+	got, err := Expand(lines(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"@R0", "D=M // from stays in comments", "@R1", "M=D",
+		"@x", "D=M // from stays in comments", "@y", "M=D",
+	}
+	if strings.Join(texts(got), "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(texts(got), "\n"), strings.Join(want, "\n"))
+	}
+
+	// expanded lines keep the position in the macro body
+	if got[0].Line != 2 || got[4].Line != 2 {
+		t.Errorf("got lines %d and %d, want 2 and 2", got[0].Line, got[4].Line)
+	}
+}
+
+func TestWholeSymbols(t *testing.T) {
+	src := `.macro SET n
+   @n
+   @nn
+   @n.1
+.endm
+SET 5`
+
+	got, err := Expand(lines(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "@5 @nn @n.1"
+	if strings.Join(texts(got), " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(texts(got), " "), want)
+	}
+}
+
+func TestLocalLabels(t *testing.T) {
+	src := `.macro WAIT
+(LOOP)
+   @LOOP
+   0;JMP
+.endm
+WAIT
+WAIT
+@LOOP`
+
+	got, err := Expand(lines(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"(WAIT$LOOP$1)", "@WAIT$LOOP$1", "0;JMP",
+		"(WAIT$LOOP$2)", "@WAIT$LOOP$2", "0;JMP",
+		"@LOOP", // outside of the macro the name is not renamed
+	}
+	if strings.Join(texts(got), "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(texts(got), "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRecursion(t *testing.T) {
+	tests := []string{
+		".macro A\nA\n.endm\nA",
+		".macro B\nB\nB\n.endm\nB",
+		".macro C\nD\n.endm\n.macro D\nC\n.endm\nC",
+	}
+
+	for _, src := range tests {
+		_, err := Expand(lines(src))
+		if err == nil || !strings.Contains(err.Error(), "invokes itself") {
+			t.Errorf("%q: got %v, want an error that the macro invokes itself", src, err)
+		}
+	}
+}
+
+func TestTooManyLines(t *testing.T) {
+	// every macro invokes the one before it 4 times: 4^10 lines
+	src := ".macro M0\n@0\n.endm\n"
+	for i := 1; i <= 10; i++ {
+		prev := "M" + strconv.Itoa(i-1)
+		src += ".macro M" + strconv.Itoa(i) + "\n" + strings.Repeat(prev+"\n", 4) + ".endm\n"
+	}
+	src += "M10"
+
+	_, err := Expand(lines(src))
+	if err == nil || !strings.Contains(err.Error(), "more than") {
+		t.Errorf("got %v, want an error that the macros expand to too many lines", err)
+	}
+}
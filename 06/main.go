@@ -1,29 +1,115 @@
 package main
 
 import (
+	"assembler/code"
+	"assembler/lint"
+	"assembler/listing"
+	"assembler/object"
+	"assembler/optimize"
 	"assembler/parser"
-	"bufio"
+	"assembler/rom"
+	"assembler/source"
+	"assembler/symbol"
+	"assembler/verify"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
-var assembly = flag.String("asm", "", "Assembly file location")
+var assembly fileList
+var output = flag.String("out", "", "Output file location, - for stdout. Named after the first assembly file when empty, stdout when reading stdin")
+var errorFormat = flag.String("errors", "text", "Error output format: text or json")
+var expandOnly = flag.Bool("expand", false, "Print the source with includes and macros expanded instead of assembling it")
+var objectOnly = flag.Bool("obj", false, "Write a relocatable .hobj object file for the linker instead of a ROM image")
+var writeListing = flag.Bool("list", false, "Also write a .lst listing with addresses, encodings, source lines and symbols")
+var symbolMap = flag.String("sym", "", "Also write the symbol map: text for a .sym file, json for a .sym.json file")
+var outputFormat = flag.String("format", "hack", "Output format: "+strings.Join(rom.Formats(), ", "))
+var lintOnly = flag.Bool("lint", false, "Report likely mistakes such as unused labels and unreachable code instead of assembling")
+var optimizations = flag.String("optimize", "", "Optimizations to run before encoding: all, or a comma separated list of "+strings.Join(passNames(), ", "))
+var variableLimit = flag.Int("varlimit", parser.DefaultVariableLimit, "First RAM address variables can't be allocated at, SCREEN by default")
+var expected = flag.String("verify", "", "Compare the program to an expected .hack file, or to an .asm file assembled the same way, instead of writing it")
+var extended = flag.Bool("extended", false, "Allow the shifts of the extended ALU (D<<, A>>, M<< ...) without warnings")
+
+// warnings are collected while assembling and reported with the errors, or
+// on their own when the assembly succeeds.
+var warnings parser.ErrorList
+
+// fileList is a flag that can be given more than once, one file each time.
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *fileList) Set(name string) error {
+	*f = append(*f, name)
+
+	return nil
+}
+
+// assemblies are every input file. They share one symbol table and the
+// output file is named after the first one. It is empty when reading stdin.
+var assemblies []string
 
 func init() {
+	flag.Var(&assembly, "asm", "Assembly file location. Repeat it, or name more files after the flags, to assemble several files. Reads stdin when empty or -")
 	flag.Parse()
 
-	assertBlank(assembly, "asm")
+	for _, name := range append(assembly, flag.Args()...) {
+		if name != "" && name != "-" {
+			assemblies = append(assemblies, name)
+		}
+	}
+	for i := range assemblies {
+		validateFileFormat(&assemblies[i], "asm")
+	}
+
+	if *errorFormat != "text" && *errorFormat != "json" {
+		log.Fatalf("errors must be text or json, not %s", *errorFormat)
+	}
 
-	validateFileFormat(assembly, "asm")
+	if *symbolMap != "" && *symbolMap != "text" && *symbolMap != "json" {
+		log.Fatalf("sym must be text or json, not %s", *symbolMap)
+	}
+
+	if *optimizations != "" {
+		if _, err := optimize.ParsePasses(*optimizations); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if *objectOnly {
+			log.Fatalf("optimize can't be used with obj, the linker moves the code")
+		}
+	}
+
+	if *expected != "" {
+		if !strings.HasSuffix(*expected, ".asm") {
+			validateFileFormat(expected, "hack")
+		}
+		if *objectOnly || *lintOnly || *expandOnly {
+			log.Fatalf("verify can't be used with obj, lint or expand")
+		}
+	}
+
+	if *variableLimit <= 16 || *variableLimit > code.MaxAddress+1 {
+		log.Fatalf("varlimit must be between 17 and %d, not %d", code.MaxAddress+1, *variableLimit)
+	}
+
+	if _, ok := rom.Lookup(*outputFormat); !ok {
+		log.Fatalf("format must be one of %s, not %s", strings.Join(rom.Formats(), ", "), *outputFormat)
+	}
 }
 
-func assertBlank(loc *string, flag string) {
-	if loc == nil || *loc == "" {
-		log.Fatalf("%s can't be empty", flag)
+func passNames() []string {
+	names := make([]string, len(optimize.Passes))
+	for i, pass := range optimize.Passes {
+		names[i] = string(pass)
 	}
+
+	return names
 }
 
 func validateFileFormat(name *string, format string) {
@@ -35,56 +121,293 @@ func validateFileFormat(name *string, format string) {
 
 func main() {
 
-	file, err := os.Open(*assembly)
+	lines, err := readSource()
 	if err != nil {
-		log.Fatalf("Can't be open file: %s", *assembly)
+		reportErrors(err)
+		os.Exit(1)
 	}
-	defer file.Close()
 
-	parser := parser.New(file)
-	result := make([]string, 0)
+	if *expandOnly {
+		expanded, err := parser.Preprocess(lines)
+		if err != nil {
+			reportErrors(err)
+			os.Exit(1)
+		}
+
+		if err := source.WriteLines(os.Stdout, expanded); err != nil {
+			log.Fatalf("%v", err)
+		}
 
-	// phase 1
-	for parser.HasMoreCommands() {
-		parser.Advance()
+		return
 	}
 
-	parser.Rewind()
+	if *objectOnly {
+		writeObject(lines)
+
+		return
+	}
 
-	// phase 2
-	for parser.HasMoreCommands() {
-		parser.Advance()
+	if *lintOnly {
+		lintProgram(lines)
 
-		binCode := parser.BinaryCode()
-		if binCode != "" {
-			result = append(result, binCode)
+		return
+	}
+
+	if *optimizations != "" {
+		lines = optimizeProgram(lines)
+	}
+
+	result, symbols, entries, err := parser.AssembleListing(lines, options())
+	if err != nil {
+		reportErrors(err)
+		os.Exit(1)
+	}
+	reportWarnings()
+
+	if *expected != "" {
+		verifyProgram(result)
+
+		return
+	}
+
+	if *writeListing {
+		lstFile := createOutput("lst", false)
+		defer lstFile.Close()
+
+		if err := listing.Write(lstFile, entries, symbols); err != nil {
+			log.Fatalf("%v", err)
 		}
 	}
 
-	binFile := makeBinFile(*assembly)
+	if *symbolMap != "" {
+		writeSymbolMap(symbols)
+	}
+
+	writer, _ := rom.Lookup(*outputFormat)
+
+	binFile := createOutput(writer.Extension(), true)
 	defer binFile.Close()
 
-	writer := bufio.NewWriter(binFile)
-	for _, binaryCode := range result {
-		_, err := writer.WriteString(binaryCode + "\n")
+	if err := writer.Write(binFile, result); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	printSummary(result, symbols)
+}
+
+// verifyProgram compares the assembled program to the expected one and
+// exits with status 1 when they differ.
+func verifyProgram(result []uint16) {
+	name := "the program"
+	if len(assemblies) > 0 {
+		name = assemblies[0]
+	}
+
+	err := verify.Expected(result, *expected)
+	if m, ok := err.(*verify.Mismatch); ok {
+		fmt.Printf("%s and %s: %v\n", name, *expected, m)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	fmt.Printf("%s matches %s\n", name, *expected)
+}
+
+// printSummary tells how much of ROM and of the RAM for variables the
+// program uses.
+func printSummary(result []uint16, symbols *symbol.SymbolTable) {
+	variables := 0
+	for _, entry := range symbols.Entries() {
+		if entry.Kind == symbol.VARIABLE {
+			variables++
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "ROM: %d of %d words (%.1f%%), RAM: %d of %d variables (%.1f%%)\n",
+		len(result), code.ROMSize, percent(len(result), code.ROMSize),
+		variables, *variableLimit-16, percent(variables, *variableLimit-16))
+}
+
+func percent(n int, of int) float64 {
+	return float64(n) * 100 / float64(of)
+}
+
+// optimizeProgram returns the lines of the optimized program and reports
+// how many ROM words each optimization saved.
+func optimizeProgram(lines []source.Line) []source.Line {
+	program, symbols, err := parser.Parse(lines, parser.Options{Extended: *extended})
+	if err != nil {
+		reportErrors(err)
+		os.Exit(1)
+	}
+
+	passes, _ := optimize.ParsePasses(*optimizations)
+
+	optimized, err := optimize.Optimize(program, symbols, passes)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	total := 0
+	for _, pass := range passes {
+		total += optimized.Saved[pass]
+		fmt.Fprintf(os.Stderr, "%s: %d words\n", pass, optimized.Saved[pass])
+	}
+	fmt.Fprintf(os.Stderr, "saved %d of %d ROM words\n", total, optimized.Words)
+
+	return optimized.Lines
+}
+
+func lintProgram(lines []source.Line) {
+	program, symbols, err := parser.Parse(lines, options())
+	if err != nil {
+		reportErrors(err)
+		os.Exit(1)
+	}
+
+	problems := append(warnings, lint.Lint(program, symbols)...)
+	if len(problems) > 0 {
+		printErrors(os.Stdout, problems)
+		os.Exit(1)
+	}
+}
+
+func writeSymbolMap(symbols *symbol.SymbolTable) {
+	if *symbolMap == "json" {
+		symFile := createOutput("sym.json", false)
+		defer symFile.Close()
+
+		if err := symbol.WriteMapJSON(symFile, symbols.Entries()); err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		return
+	}
+
+	symFile := createOutput("sym", false)
+	defer symFile.Close()
+
+	if err := symbol.WriteMap(symFile, symbols.Entries()); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+func writeObject(lines []source.Line) {
+	name := "stdin"
+	if len(assemblies) > 0 {
+		name = strings.TrimSuffix(filepath.Base(assemblies[0]), ".asm")
+	}
+
+	obj, err := parser.AssembleObject(lines, name, options())
+	if err != nil {
+		reportErrors(err)
+		os.Exit(1)
+	}
+	reportWarnings()
+
+	objFile := createOutput("hobj", true)
+	defer objFile.Close()
+
+	if err := object.Write(objFile, obj); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+func options() parser.Options {
+	return parser.Options{
+		Extended:      *extended,
+		VariableLimit: *variableLimit,
+		Warn: func(w *parser.Error) {
+			warnings = append(warnings, w)
+		},
+	}
+}
+
+func reportErrors(err error) {
+	errs, ok := err.(parser.ErrorList)
+	if !ok {
+		log.Fatalf("%v", err)
+	}
+
+	printErrors(os.Stdout, append(warnings, errs...))
+}
+
+// reportWarnings prints the warnings of a successful assembly. They always go
+// to stderr, because stdout may be the ROM image.
+func reportWarnings() {
+	if len(warnings) > 0 {
+		printErrors(os.Stderr, warnings)
+	}
+}
+
+// printErrors prints errs as JSON to jsonOut, or as text to stderr.
+func printErrors(jsonOut *os.File, errs parser.ErrorList) {
+	if *errorFormat == "json" {
+		out, err := json.MarshalIndent(errs, "", "  ")
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Fprintln(jsonOut, string(out))
+
+		return
+	}
+
+	for _, e := range errs {
+		fmt.Fprintln(os.Stderr, e)
+	}
+}
+
+func readSource() ([]source.Line, error) {
+	if len(assemblies) == 0 {
+		return source.ReadLines(os.Stdin, "<stdin>")
+	}
+
+	return source.Load(assemblies...)
+}
+
+// createOutput creates the file for one output. The primary output goes to
+// -out, and to stdout when reading stdin. Other outputs, such as listings,
+// are named after -out or the first assembly file with their own extension.
+func createOutput(extension string, primary bool) *os.File {
+	if primary && (*output == "-" || (*output == "" && len(assemblies) == 0)) {
+		return os.Stdout
+	}
+
+	if *output != "" && *output != "-" {
+		name := *output
+		if !primary {
+			name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + extension
+		}
+
+		file, err := os.Create(name)
 		if err != nil {
-			fmt.Printf("%v", err)
+			log.Fatalf("Can't create file: %s", name)
 		}
+		return file
+	}
+
+	if len(assemblies) == 0 {
+		log.Fatalf("a .%s file needs -asm or -out to be named", extension)
 	}
-	writer.Flush()
+
+	return makeBinFile(assemblies[0], extension)
 }
 
-func makeBinFile(asmFileLoc string) *os.File {
+func makeBinFile(asmFileLoc string, extension string) *os.File {
 	var binFileName string
 
 	if strings.Contains(asmFileLoc, "/") {
 		loc := strings.Split(asmFileLoc, "/")
 		asmFile := loc[len(loc)-1]
-		binFileName = "./" + strings.Split(asmFile, ".")[0] + ".hack"
+		binFileName = "./" + strings.Split(asmFile, ".")[0] + "." + extension
 	} else if strings.Contains(asmFileLoc, "\\") {
 		loc := strings.Split(asmFileLoc, "\\")
 		asmFile := loc[len(loc)-1]
-		binFileName = ".\\" + strings.Split(asmFile, ".")[0] + ".hack"
+		binFileName = ".\\" + strings.Split(asmFile, ".")[0] + "." + extension
+	} else {
+		binFileName = "./" + strings.Split(asmFileLoc, ".")[0] + "." + extension
 	}
 
 	binFile, err := os.Create(binFileName)
@@ -0,0 +1,172 @@
+package object
+
+import (
+	"assembler/code"
+	"assembler/symbol"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LinkError is a problem with one symbol while linking.
+type LinkError struct {
+	Module string
+	Symbol string
+	Msg    string
+}
+
+func (e *LinkError) Error() string {
+	if e.Symbol == "" {
+		return fmt.Sprintf("%s: %s", e.Module, e.Msg)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", e.Module, e.Symbol, e.Msg)
+}
+
+// LinkErrors is every LinkError found by Link.
+type LinkErrors []*LinkError
+
+func (errs LinkErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Link places objs one after another in ROM, resolves their references to
+// each other and allocates their variables from address 16 in order of
+// first use, below variableLimit, or symbol.DefaultVariableLimit when it is
+// 0. The returned symbol table holds the exported labels and the variables.
+func Link(objs []*Object, variableLimit int) ([]uint16, *symbol.SymbolTable, error) {
+	var errs LinkErrors
+
+	if variableLimit == 0 {
+		variableLimit = symbol.DefaultVariableLimit
+	}
+
+	st := symbol.New()
+
+	bases := make([]int, len(objs))
+	exportedBy := make(map[string]string)
+	size := 0
+	for i, obj := range objs {
+		bases[i] = size
+		size += len(obj.Code)
+
+		for _, name := range sortedExports(obj) {
+			if other, exist := exportedBy[name]; exist {
+				errs = append(errs, &LinkError{Module: obj.Name, Symbol: name, Msg: "already exported by " + other})
+				continue
+			}
+
+			exportedBy[name] = obj.Name
+			st.AddEntry(name, bases[i]+obj.Exports[name], symbol.LABEL)
+		}
+	}
+
+	if size > code.ROMSize {
+		errs = append(errs, &LinkError{Module: objs[len(objs)-1].Name, Msg: fmt.Sprintf("program is %d words, ROM holds %d", size, code.ROMSize)})
+	}
+
+	next := symbol.FirstVariable
+	unplaced := make(map[string]bool) // variables past variableLimit
+	for _, obj := range objs {
+		for _, name := range obj.Variables {
+			if kind, exist := st.Kind(name); exist && kind == symbol.VARIABLE {
+				continue
+			} else if exist {
+				errs = append(errs, &LinkError{Module: obj.Name, Symbol: name, Msg: "used as a variable but exported by " + exportedBy[name] + ", declare it with .extern"})
+				continue
+			}
+
+			if next >= variableLimit {
+				unplaced[name] = true
+				errs = append(errs, &LinkError{Module: obj.Name, Symbol: name, Msg: fmt.Sprintf("no room for the variable, variables must be below %d", variableLimit)})
+				continue
+			}
+
+			st.AddEntry(name, next, symbol.VARIABLE)
+			next++
+		}
+	}
+
+	program := make([]uint16, 0, size)
+	for i, obj := range objs {
+		words := make([]uint16, len(obj.Code))
+		copy(words, obj.Code)
+
+		for _, reloc := range obj.Relocations {
+			var value int
+			switch reloc.Kind {
+			case LOCAL:
+				value = bases[i] + reloc.Offset
+			case EXTERN:
+				if _, exist := exportedBy[reloc.Symbol]; !exist {
+					errs = append(errs, &LinkError{Module: obj.Name, Symbol: reloc.Symbol, Msg: "not exported by any module"})
+					continue
+				}
+
+				address, _ := st.GetAddress(reloc.Symbol)
+				value = address + reloc.Offset
+			case VARIABLE:
+				if unplaced[reloc.Symbol] {
+					continue
+				}
+
+				address, isExist := st.GetAddress(reloc.Symbol)
+				if kind, _ := st.Kind(reloc.Symbol); !isExist || kind != symbol.VARIABLE {
+					errs = append(errs, &LinkError{Module: obj.Name, Symbol: reloc.Symbol, Msg: "variable is not listed in the object"})
+					continue
+				}
+
+				value = address + reloc.Offset
+			default:
+				errs = append(errs, &LinkError{Module: obj.Name, Symbol: reloc.Symbol, Msg: fmt.Sprintf("unknown relocation kind %q", reloc.Kind)})
+				continue
+			}
+
+			if value < 0 || value > code.MaxAddress {
+				errs = append(errs, &LinkError{Module: obj.Name, Symbol: reloc.Symbol, Msg: fmt.Sprintf("address %d doesn't fit in 15 bits", value)})
+				continue
+			}
+
+			words[reloc.Address] = uint16(value)
+		}
+
+		program = append(program, words...)
+	}
+
+	if len(errs) > 0 {
+		return nil, st, dedupe(errs)
+	}
+
+	return program, st, nil
+}
+
+func sortedExports(obj *Object) []string {
+	names := make([]string, 0, len(obj.Exports))
+	for name := range obj.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// dedupe reports a missing export once per module instead of once per use.
+func dedupe(errs LinkErrors) LinkErrors {
+	seen := make(map[string]bool)
+	result := make(LinkErrors, 0, len(errs))
+	for _, e := range errs {
+		key := e.Error()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, e)
+	}
+
+	return result
+}
@@ -0,0 +1,80 @@
+package object
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Format identifies the object file layout. Read rejects other formats.
+const Format = "hack-object/1"
+
+type RelocationKind string
+
+const (
+	LOCAL    = RelocationKind("local")    // label of the same module, relative to its first word
+	EXTERN   = RelocationKind("extern")   // label exported by another module
+	VARIABLE = RelocationKind("variable") // variable the linker allocates
+)
+
+// Relocation says how the linker fills in the word at Address: the value of
+// Symbol, or of the start of the module for LOCAL, plus Offset.
+type Relocation struct {
+	Address int            `json:"address"`
+	Kind    RelocationKind `json:"kind"`
+	Symbol  string         `json:"symbol,omitempty"`
+	Offset  int            `json:"offset"`
+}
+
+// Object is one separately assembled module.
+type Object struct {
+	Format string `json:"format"`
+	Name   string `json:"name"`
+
+	Code []uint16 `json:"code"`
+
+	// Exports maps exported labels to their address inside Code.
+	Exports map[string]int `json:"exports"`
+
+	Relocations []Relocation `json:"relocations"`
+
+	// Variables are the variables the module uses, in order of first use.
+	Variables []string `json:"variables"`
+}
+
+func New(name string) *Object {
+	return &Object{
+		Format:      Format,
+		Name:        name,
+		Code:        make([]uint16, 0),
+		Exports:     make(map[string]int),
+		Relocations: make([]Relocation, 0),
+		Variables:   make([]string, 0),
+	}
+}
+
+func Write(w io.Writer, obj *Object) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(obj)
+}
+
+func Read(r io.Reader) (*Object, error) {
+	obj := &Object{}
+	if err := json.NewDecoder(r).Decode(obj); err != nil {
+		return nil, err
+	}
+
+	if obj.Format != Format {
+		return nil, fmt.Errorf("unknown object format %q", obj.Format)
+	}
+
+	for _, reloc := range obj.Relocations {
+		if reloc.Address < 0 || reloc.Address >= len(obj.Code) {
+			return nil, fmt.Errorf("relocation address %d is outside of the code", reloc.Address)
+		}
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,534 @@
+package optimize
+
+import (
+	"assembler/code"
+	"assembler/expr"
+	"assembler/parser"
+	"assembler/source"
+	"assembler/symbol"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pass names one optimization.
+type Pass string
+
+const (
+	JUMP_THREADING = Pass("jump-threading")
+	REDUNDANT_AT   = Pass("redundant-at")
+	DEAD_STORES    = Pass("dead-stores")
+)
+
+// Passes are every optimization, in the order they run.
+var Passes = []Pass{JUMP_THREADING, REDUNDANT_AT, DEAD_STORES}
+
+// ParsePasses reads a comma separated list of passes. "all" is every pass.
+func ParsePasses(list string) ([]Pass, error) {
+	if list == "all" {
+		return Passes, nil
+	}
+
+	var passes []Pass
+	for _, name := range strings.Split(list, ",") {
+		pass := Pass(strings.TrimSpace(name))
+		if !isPass(pass) {
+			return nil, fmt.Errorf("unknown optimization %q", name)
+		}
+		passes = append(passes, pass)
+	}
+
+	return passes, nil
+}
+
+func isPass(pass Pass) bool {
+	for _, p := range Passes {
+		if p == pass {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Result is an optimized program.
+type Result struct {
+	Lines []source.Line // the program to assemble instead of the original
+	Saved map[Pass]int  // ROM words removed by each pass
+	Words int           // ROM words of the original program
+}
+
+// op is a statement the optimizer may rewrite or remove.
+type op struct {
+	parser.Statement
+
+	// pinned is set on the first use of a variable, because removing it
+	// would change the addresses the assembler gives to variables, and on
+	// the instructions up to a constant a computed jump may go to.
+	pinned bool
+}
+
+type optimizer struct {
+	ops    []op
+	labels map[string]int // address of every label before optimizing
+	saved  map[Pass]int
+}
+
+// Optimize runs passes over a program returned by parser.Parse until none
+// of them finds anything more to do:
+//
+//   - jump-threading makes a jump to an unconditional jump go straight to its
+//     target, and removes unconditional jumps to the next instruction
+//   - redundant-at removes A-Instructions that load the value A already holds
+//   - dead-stores removes stores to A and D that are overwritten before they
+//     are read, and increments that the next instruction undoes
+//
+// Jumps to constant addresses are given labels first, so that instructions
+// can move. When a program jumps to computed addresses, the constants it
+// reads as values may be such addresses, so the instructions up to the
+// largest of them are left alone. Programs that compute addresses from
+// labels, or that jump to computed addresses without ever loading a label
+// or a constant as a value, can't be optimized, because their targets can't
+// be told apart from plain numbers.
+func Optimize(program []parser.Statement, symbols *symbol.SymbolTable, passes []Pass) (*Result, error) {
+	o := &optimizer{labels: make(map[string]int), saved: make(map[Pass]int)}
+	for _, entry := range symbols.Entries() {
+		if entry.Kind == symbol.LABEL {
+			o.labels[entry.Name] = entry.Address
+		}
+	}
+
+	if err := o.load(program, symbols); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Saved: o.saved}
+	for _, op := range o.ops {
+		if op.Instruction != nil {
+			result.Words++
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, pass := range passes {
+			var saved int
+			switch pass {
+			case JUMP_THREADING:
+				saved = o.threadJumps()
+			case REDUNDANT_AT:
+				saved = o.removeRedundantAt()
+			case DEAD_STORES:
+				saved = o.removeDeadStores()
+			}
+			if saved > 0 {
+				o.saved[pass] += saved
+				changed = true
+			}
+		}
+	}
+
+	for _, op := range o.ops {
+		result.Lines = append(result.Lines, source.Line{Text: op.Text, File: op.Pos.File, Line: op.Pos.Line})
+	}
+
+	return result, nil
+}
+
+// load copies program into o.ops, replacing constant jump targets with
+// labels.
+func (o *optimizer) load(program []parser.Statement, symbols *symbol.SymbolTable) error {
+	used := make(map[string]bool)
+	targets := make(map[int]string)
+
+	var (
+		indirect   bool  // a jump to an address computed by a C-Instruction
+		labelValue bool  // a label is read as a value, like a return address
+		computed   bool  // A was last set by a C-Instruction
+		constants  []int // constants read as values, as in @12 D=A
+	)
+	for i, s := range program {
+		switch inst := s.Instruction.(type) {
+		case code.AInstruction:
+			computed = false
+
+			e, err := expr.Parse(s.Symbol)
+			if err != nil {
+				return err
+			}
+			names := expr.Symbols(e)
+			for _, name := range names {
+				if kind, _ := symbols.Kind(name); kind == symbol.LABEL && !expr.IsSymbol(s.Symbol) {
+					return fmt.Errorf("%s: can't optimize %q, it computes an address from label %s", s.Pos, s.Text, name)
+				}
+			}
+
+			next, ok := nextInstruction(program, i)
+			if !ok {
+				continue
+			}
+			if len(names) == 0 && next.Jump != "" {
+				targets[int(inst.Value)] = ""
+			}
+			if strings.Contains(next.Comp, "A") {
+				if kind, _ := symbols.Kind(s.Symbol); kind == symbol.LABEL {
+					labelValue = true
+				} else {
+					constants = append(constants, int(inst.Value))
+				}
+			}
+		case code.CInstruction:
+			if inst.Jump != "" && computed {
+				indirect = true
+			}
+			if strings.Contains(inst.Dest, "A") {
+				computed = true
+			}
+		}
+	}
+
+	words := 0
+	for _, s := range program {
+		if s.Instruction != nil {
+			words++
+		}
+	}
+
+	// A constant read as a value may be the address a computed jump goes
+	// to, so the instructions up to it must stay where they are.
+	fixed := -1
+	for _, c := range constants {
+		if indirect && c <= words && c > fixed {
+			fixed = c
+		}
+	}
+	if indirect && !labelValue && fixed < 0 {
+		return fmt.Errorf("can't optimize a program that jumps to computed addresses but never loads a label or a constant address as a value")
+	}
+
+	for address := range targets {
+		if address > words {
+			return fmt.Errorf("can't optimize a jump to %d, past the end of the program", address)
+		}
+
+		name := "ROM." + strconv.Itoa(address)
+		for i := 1; symbols.Contains(name); i++ {
+			name = fmt.Sprintf("ROM.%d.%d", address, i)
+		}
+		targets[address] = name
+		o.labels[name] = address
+	}
+
+	pending := make(map[int]bool)
+	for address := range targets {
+		pending[address] = true
+	}
+
+	for i, s := range program {
+		if pending[s.Address] && s.Instruction != nil {
+			o.ops = append(o.ops, op{Statement: label(s, targets[s.Address]), pinned: s.Address <= fixed})
+			delete(pending, s.Address)
+		}
+
+		a, ok := s.Instruction.(code.AInstruction)
+		if !ok {
+			o.ops = append(o.ops, op{Statement: s, pinned: s.Address <= fixed})
+
+			continue
+		}
+
+		e, _ := expr.Parse(s.Symbol)
+		names := expr.Symbols(e)
+
+		pinned := s.Address <= fixed
+		for _, name := range names {
+			if kind, _ := symbols.Kind(name); kind == symbol.VARIABLE && !used[name] {
+				pinned = true
+			}
+			used[name] = true
+		}
+
+		if next, ok := nextInstruction(program, i); ok && next.Jump != "" && len(names) == 0 {
+			s = load(s, targets[int(a.Value)], int(a.Value))
+		}
+
+		o.ops = append(o.ops, op{Statement: s, pinned: pinned})
+	}
+
+	// targets past the last instruction
+	for address := range pending {
+		s := parser.Statement{Address: address}
+		if len(program) > 0 {
+			s.Pos = program[len(program)-1].Pos
+		}
+		o.ops = append(o.ops, op{Statement: label(s, targets[address])})
+	}
+
+	return nil
+}
+
+// threadJumps makes jumps to an unconditional jump go to its target.
+func (o *optimizer) threadJumps() int {
+	saved := 0
+
+	for i := 0; i+1 < len(o.ops); i++ {
+		s := &o.ops[i]
+		if s.CommandType != parser.A_COMMAND {
+			continue
+		}
+		if _, ok := o.labels[s.Symbol]; !ok {
+			continue
+		}
+
+		jump, ok := o.ops[i+1].Instruction.(code.CInstruction)
+		if !ok || jump.Jump == "" || usesA(jump) {
+			continue
+		}
+
+		// an unconditional jump to the next instruction does nothing
+		if jump.Jump == "JMP" && jump.Dest == "" && o.isNext(i+2, s.Symbol) && o.setsA(o.find(s.Symbol)) && !s.pinned {
+			o.remove(i, i+2)
+			saved += 2
+			i--
+
+			continue
+		}
+
+		// on the way through a conditional jump A must be set again before
+		// it is read, since it will hold another label
+		if jump.Jump != "JMP" && !o.setsA(i+2) {
+			continue
+		}
+
+		target := o.follow(s.Symbol)
+		if target != s.Symbol {
+			o.ops[i].Statement = load(s.Statement, target, o.labels[target])
+		}
+	}
+
+	return saved
+}
+
+// follow returns the label where a jump to name ends up after going through
+// unconditional jumps.
+func (o *optimizer) follow(name string) string {
+	seen := map[string]bool{name: true}
+	for {
+		i := o.find(name)
+		if i < 0 || i+1 >= len(o.ops) {
+			return name
+		}
+
+		s := o.ops[i]
+		jump, ok := o.ops[i+1].Instruction.(code.CInstruction)
+		if s.CommandType != parser.A_COMMAND || !ok || jump.Jump != "JMP" || jump.Dest != "" {
+			return name
+		}
+		if _, ok := o.labels[s.Symbol]; !ok || seen[s.Symbol] {
+			return name
+		}
+
+		name = s.Symbol
+		seen[name] = true
+	}
+}
+
+// find returns the index of the first instruction after label name.
+func (o *optimizer) find(name string) int {
+	for i, op := range o.ops {
+		if op.CommandType == parser.L_COMMAND && op.Symbol == name {
+			for i < len(o.ops) && o.ops[i].CommandType == parser.L_COMMAND {
+				i++
+			}
+
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isNext reports whether label name is among the labels starting at i.
+func (o *optimizer) isNext(i int, name string) bool {
+	for ; i < len(o.ops) && o.ops[i].CommandType == parser.L_COMMAND; i++ {
+		if o.ops[i].Symbol == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setsA reports whether the instruction at i is an A-Instruction.
+func (o *optimizer) setsA(i int) bool {
+	return i >= 0 && i < len(o.ops) && o.ops[i].CommandType == parser.A_COMMAND
+}
+
+// removeRedundantAt removes A-Instructions that load the value A holds.
+func (o *optimizer) removeRedundantAt() int {
+	saved := 0
+
+	var (
+		known bool
+		value string
+	)
+	for i := 0; i < len(o.ops); i++ {
+		s := &o.ops[i]
+
+		switch inst := s.Instruction.(type) {
+		case code.AInstruction:
+			v := o.value(s)
+			if known && v == value && !s.pinned {
+				o.remove(i, i+1)
+				saved++
+				i--
+
+				continue
+			}
+			known = true
+			value = v
+		case code.CInstruction:
+			if strings.Contains(inst.Dest, "A") {
+				known = false
+			}
+		default:
+			known = false
+		}
+	}
+
+	return saved
+}
+
+// value identifies what an A-Instruction loads. Labels are kept apart from
+// numbers because they move when instructions are removed.
+func (o *optimizer) value(s *op) string {
+	if address, ok := o.labels[s.Symbol]; ok {
+		return "label " + strconv.Itoa(address)
+	}
+
+	return strconv.Itoa(int(s.Instruction.(code.AInstruction).Value))
+}
+
+// removeDeadStores removes stores to A and D that are overwritten before
+// they are read, and pairs like M=M+1 M=M-1.
+func (o *optimizer) removeDeadStores() int {
+	saved := 0
+
+	for i := 0; i < len(o.ops); i++ {
+		s := &o.ops[i]
+		if s.pinned {
+			continue
+		}
+
+		switch inst := s.Instruction.(type) {
+		case code.AInstruction:
+			if o.isDead(i, "A") {
+				o.remove(i, i+1)
+				saved++
+				i--
+			}
+		case code.CInstruction:
+			if inst.Jump != "" {
+				continue
+			}
+
+			if (inst.Dest == "A" || inst.Dest == "D") && o.isDead(i, inst.Dest) {
+				o.remove(i, i+1)
+				saved++
+				i--
+
+				continue
+			}
+
+			if i+1 < len(o.ops) && undoes(inst, o.ops[i+1].Instruction) {
+				o.remove(i, i+2)
+				saved += 2
+				i--
+			}
+		}
+	}
+
+	return saved
+}
+
+// isDead reports whether the value the instruction at i stores in register
+// is overwritten before it is read. Labels and jumps end the search,
+// because the value may be read after them.
+func (o *optimizer) isDead(i int, register string) bool {
+	for _, s := range o.ops[i+1:] {
+		switch inst := s.Instruction.(type) {
+		case code.AInstruction:
+			if register == "A" {
+				return true
+			}
+		case code.CInstruction:
+			if reads(inst, register) || inst.Jump != "" {
+				return false
+			}
+			if strings.Contains(inst.Dest, register) {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+func reads(inst code.CInstruction, register string) bool {
+	if register == "A" {
+		return usesA(inst)
+	}
+
+	return strings.Contains(inst.Comp, register)
+}
+
+// usesA reports whether inst reads A as a value or as the address of M.
+func usesA(inst code.CInstruction) bool {
+	return strings.Contains(inst.Comp, "A") || strings.Contains(inst.Comp, "M") || strings.Contains(inst.Dest, "M")
+}
+
+// undoes reports whether next takes back the increment or decrement of inst.
+func undoes(inst code.CInstruction, next code.Instruction) bool {
+	n, ok := next.(code.CInstruction)
+	if !ok || n.Jump != "" || len(inst.Dest) != 1 || n.Dest != inst.Dest {
+		return false
+	}
+
+	r := inst.Dest
+
+	return inst.Comp == r+"+1" && n.Comp == r+"-1" || inst.Comp == r+"-1" && n.Comp == r+"+1"
+}
+
+func (o *optimizer) remove(from int, to int) {
+	o.ops = append(o.ops[:from], o.ops[to:]...)
+}
+
+func nextInstruction(program []parser.Statement, i int) (code.CInstruction, bool) {
+	if i+1 >= len(program) {
+		return code.CInstruction{}, false
+	}
+
+	inst, ok := program[i+1].Instruction.(code.CInstruction)
+
+	return inst, ok
+}
+
+func label(s parser.Statement, name string) parser.Statement {
+	return parser.Statement{
+		Pos:         s.Pos,
+		Text:        "(" + name + ")",
+		CommandType: parser.L_COMMAND,
+		Symbol:      name,
+		Address:     s.Address,
+	}
+}
+
+func load(s parser.Statement, name string, address int) parser.Statement {
+	s.Text = "@" + name
+	s.Symbol = name
+	s.Instruction = code.AInstruction{Value: uint16(address)}
+
+	return s
+}
@@ -0,0 +1,207 @@
+package optimize
+
+import (
+	"assembler/emulator"
+	"assembler/parser"
+	"assembler/source"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var programs = map[string]string{
+	// the return address 12 is a constant, the jump to it is computed
+	"computed jump": `
+   @12
+   D=A
+   @R15
+   M=D
+   @X
+   D=A
+   @1
+   @1
+   M=1
+   @R15
+   A=M
+   0;JMP
+(X)
+   @99
+   D=A
+   @R0
+   M=D
+(END)
+   @END
+   0;JMP`,
+
+	// RAM[2] = RAM[0] * RAM[1], with redundant loads and dead stores
+	"multiply": `
+   @2
+   M=0
+   @1
+   D=M
+   @i
+   M=D
+(LOOP)
+   @i
+   D=M
+   @END
+   D;JEQ
+   @0
+   D=M
+   D=0
+   D=M
+   @2
+   M=D+M
+   @i
+   @i
+   M=M-1
+   @LOOP
+   0;JMP
+(END)
+   @END
+   0;JMP`,
+
+	// a subroutine called with a label as its return address
+	"subroutine": `
+   @RET1
+   D=A
+   @R15
+   M=D
+   @DOUBLE
+   0;JMP
+(RET1)
+   @RET2
+   D=A
+   @R15
+   M=D
+   @DOUBLE
+   0;JMP
+(RET2)
+   @5
+   0;JMP
+(DOUBLE)
+   @R0
+   D=M
+   M=D+M
+   @R15
+   A=M
+   0;JMP`,
+}
+
+func lines(text string) []source.Line {
+	var result []source.Line
+	for i, line := range strings.Split(text, "\n") {
+		result = append(result, source.Line{Text: line, File: "test.asm", Line: i + 1})
+	}
+
+	return result
+}
+
+// samples are programs of the repository that halt.
+var samples = []string{"../max/Max.asm", "../max/MaxL.asm", "../rect/Rect.asm", "../rect/RectL.asm"}
+
+// run assembles lines and returns the RAM after the program halts.
+func run(t *testing.T, lines []source.Line) []uint16 {
+	t.Helper()
+
+	words, _, err := parser.AssembleLines(lines, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := emulator.New(words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RAM[0], c.RAM[1] = 6, 7
+	if err := c.Run(10000); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Halted() {
+		t.Fatalf("program doesn't halt")
+	}
+
+	return append([]uint16(nil), c.RAM[:]...)
+}
+
+func TestPassesKeepResults(t *testing.T) {
+	sources := make(map[string]string)
+	for name, src := range programs {
+		sources[name] = src
+	}
+	for _, name := range samples {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[name] = string(b)
+	}
+
+	for name, src := range sources {
+		want := run(t, lines(src))
+
+		for _, passes := range append([][]Pass{Passes}, singles()...) {
+			program, symbols, err := parser.Parse(lines(src), parser.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := Optimize(program, symbols, passes)
+			if err != nil {
+				t.Errorf("%s %v: %v", name, passes, err)
+
+				continue
+			}
+
+			got := run(t, result.Lines)
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("%s %v: RAM[%d] is %d, want %d", name, passes, i, got[i], want[i])
+
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestComputedJumpToConstant(t *testing.T) {
+	program, symbols, err := parser.Parse(lines(programs["computed jump"]), parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Optimize(program, symbols, Passes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := run(t, result.Lines); got[0] != 99 {
+		t.Errorf("RAM[0] is %d, want 99", got[0])
+	}
+}
+
+func TestRefusesUnknownTargets(t *testing.T) {
+	src := `
+   @R15
+   A=M
+   0;JMP`
+
+	program, symbols, err := parser.Parse(lines(src), parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Optimize(program, symbols, Passes); err == nil {
+		t.Errorf("got no error for a computed jump to an unknown address")
+	}
+}
+
+func singles() [][]Pass {
+	var result [][]Pass
+	for _, pass := range Passes {
+		result = append(result, []Pass{pass})
+	}
+
+	return result
+}
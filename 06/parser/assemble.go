@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"assembler/code"
+	"assembler/listing"
+	"assembler/macro"
+	"assembler/source"
+	"assembler/symbol"
+	"io"
+)
+
+// Options changes how Assemble reads its input.
+type Options struct {
+	// FileName is only used to report positions in errors.
+	FileName string
+
+	// Extended allows the shifts of the extended Hack ALU.
+	Extended bool
+
+	// VariableLimit is the first RAM address that can't hold a variable.
+	// DefaultVariableLimit is used when it is 0.
+	VariableLimit int
+
+	// Warn, when it is not nil, is called with every warning after the
+	// assembly, even when it failed.
+	Warn func(*Error)
+
+	// externs are the symbols of another module, resolved by the linker
+	// instead of being allocated as variables.
+	externs map[string]bool
+}
+
+// Assemble translates Hack assembly read from r into machine words.
+// It runs both phases of the Parser and returns the symbol table that was
+// built while doing so. When the source has problems the returned error is
+// an ErrorList holding every one of them.
+func Assemble(r io.Reader) ([]uint16, *symbol.SymbolTable, error) {
+	return AssembleWithOptions(r, Options{})
+}
+
+func AssembleWithOptions(r io.Reader, opts Options) ([]uint16, *symbol.SymbolTable, error) {
+	lines, err := source.ReadLines(r, opts.FileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return AssembleLines(lines, opts)
+}
+
+// AssembleLines preprocesses lines and assembles the result.
+func AssembleLines(lines []source.Line, opts Options) ([]uint16, *symbol.SymbolTable, error) {
+	expanded, err := Preprocess(lines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// .export and .extern only matter to the linker
+	code, _ := splitLinkage(expanded)
+
+	result, p, err := assemble(code, opts, nil)
+	if p == nil {
+		return nil, nil, err
+	}
+
+	return result, p.SymbolTable(), err
+}
+
+// assemble runs both phases of a Parser over lines. visit, when it is not
+// nil, is called after every line of phase 2 with the ROM address of the
+// line, and the errors it returns are reported at that line. The Parser is
+// nil only when lines could not be read at all.
+func assemble(lines []source.Line, opts Options, visit func(p *Parser, address int) error) ([]uint16, *Parser, error) {
+	p := NewFromLines(lines)
+	p.SetFileName(opts.FileName)
+	p.SetExtended(opts.Extended)
+	if opts.VariableLimit != 0 {
+		p.SetVariableLimit(opts.VariableLimit)
+	}
+	p.externs = opts.externs
+
+	var errs ErrorList
+
+	// phase 1
+	for p.HasMoreCommands() {
+		if err := p.Advance(); err != nil {
+			errs.add(err)
+		}
+	}
+	if err := p.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	p.Rewind()
+
+	// phase 2
+	result := make([]uint16, 0)
+	var overflow *ROMError
+	for p.HasMoreCommands() {
+		if err := p.Advance(); err != nil {
+			errs.add(err)
+			continue
+		}
+
+		if visit != nil {
+			if err := visit(p, len(result)); err != nil {
+				errs.add(p.errorAt(err))
+			}
+		}
+
+		if inst := p.Instruction(); inst != nil {
+			if len(result) == code.ROMSize {
+				overflow = &ROMError{}
+				errs.add(p.errorAt(overflow))
+			}
+			result = append(result, inst.Encode())
+		}
+	}
+	if err := p.Err(); err != nil {
+		return nil, nil, err
+	}
+	if overflow != nil {
+		overflow.Words = len(result)
+	}
+
+	if opts.Warn != nil {
+		for _, w := range p.Warnings() {
+			opts.Warn(w)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, p, errs
+	}
+
+	return result, p, nil
+}
+
+// AssembleListing is AssembleLines that also returns a listing entry for
+// every line of the preprocessed source.
+func AssembleListing(lines []source.Line, opts Options) ([]uint16, *symbol.SymbolTable, []listing.Entry, error) {
+	expanded, err := Preprocess(lines)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	code, _ := splitLinkage(expanded)
+
+	entries := make([]listing.Entry, 0, len(code))
+	result, p, err := assemble(code, opts, func(p *Parser, address int) error {
+		entry := listing.Entry{Line: p.lines.line(), Address: address}
+		if inst := p.Instruction(); inst != nil {
+			entry.IsInstruction = true
+			entry.Word = inst.Encode()
+		}
+		entries = append(entries, entry)
+
+		return nil
+	})
+	if p == nil {
+		return nil, nil, nil, err
+	}
+
+	return result, p.SymbolTable(), entries, err
+}
+
+// Preprocess resolves the .include directives in lines and then runs the
+// macro preprocessor. Its errors are returned as an ErrorList, like the
+// errors of the assembler itself.
+func Preprocess(lines []source.Line) ([]source.Line, error) {
+	included, err := source.Include(lines)
+	if err != nil {
+		return nil, toErrorList(err)
+	}
+
+	expanded, err := macro.Expand(included)
+	if err != nil {
+		return nil, toErrorList(err)
+	}
+
+	return expanded, nil
+}
+
+func toErrorList(err error) error {
+	sourceErrs, ok := err.(source.Errors)
+	if !ok {
+		return err
+	}
+
+	errs := make(ErrorList, len(sourceErrs))
+	for i, e := range sourceErrs {
+		errs[i] = &Error{
+			Pos:  Position{File: e.Line.File, Line: e.Line.Line, Column: e.Column},
+			Text: e.Text,
+			Err:  e,
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,228 @@
+package parser
+
+import (
+	"assembler/code"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MnemonicError is returned when a dest, comp or jump field of a
+// C-Instruction is not in the code tables.
+type MnemonicError struct {
+	Field    string
+	Mnemonic string
+	Hint     string // what a valid mnemonic of Field looks like, may be ""
+}
+
+func (e *MnemonicError) Error() string {
+	if e.Hint != "" {
+		return fmt.Sprintf("can't find %s mnemonic: %q (%s)", e.Field, e.Mnemonic, e.Hint)
+	}
+
+	return fmt.Sprintf("can't find %s mnemonic: %q", e.Field, e.Mnemonic)
+}
+
+// NumberError is returned when an A-Instruction constant can't be converted.
+type NumberError struct {
+	Literal string
+	Err     error
+}
+
+func (e *NumberError) Error() string {
+	if errors.Is(e.Err, strconv.ErrRange) {
+		return fmt.Sprintf("%q is out of range", e.Literal)
+	}
+
+	return fmt.Sprintf("can't convert %q to integer", e.Literal)
+}
+
+func (e *NumberError) Unwrap() error {
+	return e.Err
+}
+
+// ExpressionError is returned when the operand of an A-Instruction is not a
+// valid expression. Offset is the position of the problem inside Expr.
+type ExpressionError struct {
+	Expr   string
+	Offset int
+	Msg    string
+}
+
+func (e *ExpressionError) Error() string {
+	return fmt.Sprintf("%s in %q", e.Msg, e.Expr)
+}
+
+// RangeError is returned when an A-Instruction operand does not fit in the
+// 15 bits of an A-Instruction.
+type RangeError struct {
+	Expr  string
+	Value int
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("%q is %d, which doesn't fit in 15 bits", e.Expr, e.Value)
+}
+
+// ExtendedError is a warning for a shift of the extended Hack ALU used
+// without asking for the extended instruction set.
+type ExtendedError struct {
+	Command string
+}
+
+func (e *ExtendedError) Error() string {
+	return fmt.Sprintf("%q uses the extended ALU, which only the CPU emulator runs (assemble with -extended to allow it)", e.Command)
+}
+
+// LiteralError is a warning for a number literal operand of an
+// A-Instruction that does not fit in 15 bits. Value is what is loaded instead.
+type LiteralError struct {
+	Literal string
+	Value   int
+}
+
+func (e *LiteralError) Error() string {
+	return fmt.Sprintf("%q doesn't fit in 15 bits, %d is loaded instead", e.Literal, e.Value)
+}
+
+// CapacityError is returned when a new variable would be allocated at Limit
+// or above, usually because RAM from SCREEN on is memory mapped.
+type CapacityError struct {
+	Symbol string
+	Limit  int
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("no room for variable %s, variables must be below %d", e.Symbol, e.Limit)
+}
+
+// ROMError is returned at the first instruction that doesn't fit in ROM.
+type ROMError struct {
+	Words int // size of the whole program
+}
+
+func (e *ROMError) Error() string {
+	return fmt.Sprintf("program is %d words, ROM holds %d", e.Words, code.ROMSize)
+}
+
+// LabelError is returned when a label is defined a second time, maybe in
+// another file that shares the symbol table.
+type LabelError struct {
+	Label    string
+	Previous Position
+}
+
+func (e *LabelError) Error() string {
+	file := e.Previous.File
+	if file == "" {
+		file = "<input>"
+	}
+
+	return fmt.Sprintf("label %s already defined at %s:%d", e.Label, file, e.Previous.Line)
+}
+
+// SyntaxError is returned when a command can't be parsed at all.
+type SyntaxError struct {
+	Command string
+	Msg     string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %q", e.Msg, e.Command)
+}
+
+// Position is a location in an assembly source file. Line and Column start at 1.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	file := p.File
+	if file == "" {
+		file = "<input>"
+	}
+
+	return fmt.Sprintf("%s:%d:%d", file, p.Line, p.Column)
+}
+
+// Error attaches a source position and the offending text to one of the
+// errors above. Warning is set for problems that don't stop the assembly.
+type Error struct {
+	Pos     Position
+	Text    string
+	Err     error
+	Warning bool
+}
+
+func (e *Error) Error() string {
+	if e.Warning {
+		return fmt.Sprintf("%s: warning: %v", e.Pos, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %v", e.Pos, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+		Text     string `json:"text"`
+		Message  string `json:"message"`
+		Severity string `json:"severity"`
+	}{
+		File:     e.Pos.File,
+		Line:     e.Pos.Line,
+		Column:   e.Pos.Column,
+		Text:     e.Text,
+		Message:  e.Err.Error(),
+		Severity: e.severity(),
+	})
+}
+
+func (e *Error) severity() string {
+	if e.Warning {
+		return "warning"
+	}
+
+	return "error"
+}
+
+// ErrorList is every error found in one run of the assembler, in source order.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	lines := make([]string, len(l))
+	for i, e := range l {
+		lines[i] = e.Error()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (l *ErrorList) add(err error) {
+	switch e := err.(type) {
+	case *Error:
+		*l = append(*l, e)
+	case ErrorList:
+		*l = append(*l, e...)
+	}
+}
+
+// Err returns nil when the list is empty, so callers don't get a non-nil
+// error interface holding an empty list.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"assembler/source"
+	"bufio"
+	"io"
+)
+
+// lineBuffer feeds source lines to the Parser. Lines read from the input are
+// kept, so phase 2 replays them instead of reading the input again, and the
+// input doesn't have to be seekable: a pipe or stdin works as well as a file.
+type lineBuffer struct {
+	scanner *bufio.Scanner // nil when there is no more input to read
+	scanErr error
+
+	lines []source.Line
+	next  int
+}
+
+func newLineBuffer(reader io.Reader) *lineBuffer {
+	return &lineBuffer{scanner: bufio.NewScanner(reader)}
+}
+
+func (b *lineBuffer) scan() bool {
+	if b.next < len(b.lines) {
+		b.next++
+		return true
+	}
+
+	if b.scanner == nil {
+		return false
+	}
+
+	if !b.scanner.Scan() {
+		b.scanErr = b.scanner.Err()
+		b.scanner = nil
+		return false
+	}
+
+	b.lines = append(b.lines, source.Line{Text: b.scanner.Text(), Line: len(b.lines) + 1})
+	b.next++
+
+	return true
+}
+
+func (b *lineBuffer) line() source.Line {
+	if b.next == 0 {
+		return source.Line{}
+	}
+
+	return b.lines[b.next-1]
+}
+
+func (b *lineBuffer) err() error {
+	return b.scanErr
+}
+
+func (b *lineBuffer) rewind() {
+	b.next = 0
+}
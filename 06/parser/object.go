@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"assembler/expr"
+	"assembler/object"
+	"assembler/source"
+	"assembler/symbol"
+	"fmt"
+	"strings"
+)
+
+// linkage is a .export or .extern directive.
+type linkage struct {
+	directive string
+	name      string
+	line      source.Line
+}
+
+// splitLinkage takes the .export and .extern directives out of lines. Each
+// directive names one or more symbols, separated by commas or spaces.
+func splitLinkage(lines []source.Line) ([]source.Line, []linkage) {
+	if !source.HasDirective(lines, ".export", ".extern") {
+		return lines, nil
+	}
+
+	code := make([]source.Line, 0, len(lines))
+	directives := make([]linkage, 0)
+
+	for _, line := range lines {
+		if word := source.FirstWord(line.Text); word != ".export" && word != ".extern" {
+			code = append(code, line)
+			continue
+		}
+
+		fields := strings.Fields(source.Code(line.Text))
+
+		for _, name := range strings.FieldsFunc(strings.Join(fields[1:], " "), func(r rune) bool {
+			return r == ',' || r == ' '
+		}) {
+			directives = append(directives, linkage{directive: fields[0], name: name, line: line})
+		}
+
+		// keep the line numbers of the instructions after it
+		code = append(code, source.Line{Text: "", File: line.File, Line: line.Line})
+	}
+
+	return code, directives
+}
+
+// AssembleObject assembles lines as one module for the linker. Labels are
+// relative to the start of the module, labels named by .export can be used
+// by other modules and symbols named by .extern must be exported by another
+// module. Every other symbol that is not a label is a variable the linker
+// allocates.
+func AssembleObject(lines []source.Line, name string, opts Options) (*object.Object, error) {
+	expanded, err := Preprocess(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	code, directives := splitLinkage(expanded)
+
+	externs := make(map[string]bool)
+	for _, d := range directives {
+		if d.directive == ".extern" {
+			externs[d.name] = true
+		}
+	}
+
+	obj := object.New(name)
+	variables := make(map[string]bool)
+
+	opts.externs = externs
+	result, p, err := assemble(code, opts, func(p *Parser, address int) error {
+		if p.Instruction() == nil || p.CommandType() != A_COMMAND {
+			return nil
+		}
+
+		operand, _ := p.Symbol()
+		e, err := expr.Parse(operand)
+		if err != nil {
+			return err
+		}
+
+		term, err := expr.EvalTerm(e, func(name string) (int, bool) {
+			if externs[name] {
+				return 0, false
+			}
+			if kind, _ := p.SymbolTable().Kind(name); kind == symbol.PREDEFINED {
+				return p.SymbolTable().GetAddress(name)
+			}
+
+			return 0, false
+		})
+		if err != nil {
+			return &ExpressionError{Expr: operand, Msg: err.Error()}
+		}
+
+		if term.Symbol == "" {
+			return nil
+		}
+
+		reloc := object.Relocation{Address: address, Symbol: term.Symbol, Offset: term.Offset}
+		kind, _ := p.SymbolTable().Kind(term.Symbol)
+		switch {
+		case externs[term.Symbol]:
+			reloc.Kind = object.EXTERN
+		case kind == symbol.LABEL:
+			label, _ := p.SymbolTable().GetAddress(term.Symbol)
+			reloc.Kind = object.LOCAL
+			reloc.Offset += label
+		default:
+			reloc.Kind = object.VARIABLE
+			if !variables[term.Symbol] {
+				variables[term.Symbol] = true
+				obj.Variables = append(obj.Variables, term.Symbol)
+			}
+		}
+
+		obj.Relocations = append(obj.Relocations, reloc)
+
+		return nil
+	})
+	if p == nil {
+		return nil, err
+	}
+
+	errs, _ := err.(ErrorList)
+	for _, d := range directives {
+		kind, _ := p.SymbolTable().Kind(d.name)
+		switch {
+		case d.directive == ".export" && kind != symbol.LABEL:
+			errs = append(errs, directiveError(d, fmt.Sprintf("%s is exported but not a label of this module", d.name)))
+		case d.directive == ".export":
+			obj.Exports[d.name], _ = p.SymbolTable().GetAddress(d.name)
+		case kind == symbol.LABEL:
+			errs = append(errs, directiveError(d, fmt.Sprintf("%s is declared .extern but is a label of this module", d.name)))
+		}
+	}
+	if err != nil && len(errs) == 0 {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	obj.Code = result
+
+	return obj, nil
+}
+
+func directiveError(d linkage, msg string) *Error {
+	e := source.Errorf(d.line, d.name, "%s", msg)
+
+	return &Error{
+		Pos:  Position{File: d.line.File, Line: d.line.Line, Column: e.Column},
+		Text: d.name,
+		Err:  e,
+	}
+}
@@ -2,11 +2,12 @@ package parser
 
 import (
 	"assembler/code"
+	"assembler/expr"
+	"assembler/source"
 	"assembler/symbol"
-	"bufio"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -20,8 +21,10 @@ const (
 )
 
 type Parser struct {
-	file    *os.File
-	scanner *bufio.Scanner
+	lines *lineBuffer
+
+	fileName string
+	text     string // text of the current line
 
 	commandType COMMAND_TYPE
 
@@ -30,36 +33,121 @@ type Parser struct {
 	comp   string
 	jump   string
 
+	destAt, compAt, jumpAt int // offsets of dest, comp and jump in text
+
 	addressCounter int // This is for new variable in A-Instruction
 	lineCounter    int // This is for location of label
 	symbolTable    *symbol.SymbolTable
+	labels         map[string]Position // where each label is defined
 
 	phase int
 
-	binaryCode string
+	instruction code.Instruction
+
+	extended      bool            // the extended ALU shifts are allowed
+	variableLimit int             // first RAM address variables can't use
+	externs       map[string]bool // symbols that are 0 until they are linked
+	warnings      ErrorList       // problems that don't stop the assembly
+}
+
+// DefaultVariableLimit keeps variables below SCREEN.
+const DefaultVariableLimit = symbol.DefaultVariableLimit
+
+// New makes a Parser that reads reader line by line. The lines are kept in
+// memory for phase 2, so reader may be a pipe.
+func New(reader io.Reader) *Parser {
+	return newParser(newLineBuffer(reader))
+}
+
+// NewFromLines makes a Parser over lines that were already read, for example
+// by the macro preprocessor. Positions are taken from the lines themselves.
+func NewFromLines(lines []source.Line) *Parser {
+	return newParser(&lineBuffer{lines: lines})
 }
 
-func New(file *os.File) *Parser {
+func newParser(lines *lineBuffer) *Parser {
 	return &Parser{
-		file:           file,
-		scanner:        bufio.NewScanner(file),
-		addressCounter: 16,
+		lines:          lines,
+		addressCounter: symbol.FirstVariable,
 		lineCounter:    0,
 		symbolTable:    symbol.New(),
+		labels:         make(map[string]Position),
 		phase:          1,
+		variableLimit:  DefaultVariableLimit,
 	}
 }
 
+func (p *Parser) SetFileName(fileName string) {
+	p.fileName = fileName
+}
+
+// SetExtended allows the shifts of the extended Hack ALU, D<<, A>> and so on.
+// Without it they are still assembled, but each one is reported as a warning.
+func (p *Parser) SetExtended(extended bool) {
+	p.extended = extended
+}
+
+// SetVariableLimit makes allocating a variable at limit or above an error.
+func (p *Parser) SetVariableLimit(limit int) {
+	p.variableLimit = limit
+}
+
+// VariableCount returns the number of variables allocated so far.
+func (p *Parser) VariableCount() int {
+	return p.addressCounter - symbol.FirstVariable
+}
+
+// Warnings returns the warnings found so far, in source order.
+func (p *Parser) Warnings() ErrorList {
+	return p.warnings
+}
+
 func (p *Parser) HasMoreCommands() bool {
-	return p.scanner.Scan()
+	if !p.lines.scan() {
+		return false
+	}
+
+	p.text = p.lines.line().Text
+
+	return true
 }
 
-func (p *Parser) Advance() {
+// Advance parses the next command. Errors are returned as *Error, or as an
+// ErrorList when the command has more than one problem, so that they carry
+// the position of the command in the source file.
+func (p *Parser) Advance() error {
+	var err error
 	if p.phase == 1 {
-		p.parseNextOnPhase1()
-	} else if p.phase == 2 {
-		p.parseNextOnPhase2()
+		err = p.parseNextOnPhase1()
+	} else {
+		err = p.parseNextOnPhase2()
+	}
+
+	if err == nil {
+		return nil
 	}
+
+	if mnemonicErrs, ok := err.(mnemonicErrors); ok {
+		errs := make(ErrorList, len(mnemonicErrs))
+		for i, e := range mnemonicErrs {
+			errs[i] = p.errorAt(e)
+		}
+
+		return errs
+	}
+
+	return p.errorAt(err)
+}
+
+func (p *Parser) Pos() Position {
+	line := p.lines.line()
+
+	file := line.File
+	if file == "" {
+		file = p.fileName
+	}
+
+	return Position{File: file, Line: line.Line, Column: 1}
 }
 
 func (p *Parser) CommandType() COMMAND_TYPE {
@@ -102,33 +190,39 @@ func (p *Parser) Jump() (string, error) {
 	return "", fmt.Errorf("invalid command type for Jump: %s", p.CommandType())
 }
 
-func (p *Parser) BinaryCode() string {
-	return p.binaryCode
+// Instruction returns the instruction produced by the last Advance in phase 2,
+// or nil when the command was a label, a comment or an empty line.
+func (p *Parser) Instruction() code.Instruction {
+	return p.instruction
+}
+
+func (p *Parser) SymbolTable() *symbol.SymbolTable {
+	return p.symbolTable
+}
+
+func (p *Parser) Err() error {
+	return p.lines.err()
 }
 
 func (p *Parser) Rewind() {
 	// Rewind Parser for phase 2
 
-	_, err := p.file.Seek(0, 0)
-	if err != nil {
-		log.Fatalf("Can't start phase 2")
-	}
-
-	p.scanner = bufio.NewScanner(p.file)
+	p.lines.rewind()
+	p.text = ""
 
 	p.phase = 2
 }
 
-func (p *Parser) parseNextOnPhase1() {
-	command := p.scanner.Text()
+func (p *Parser) parseNextOnPhase1() error {
+	command := p.text
 
 	command = strings.TrimSpace(command)
 
 	if isAbleToSkip(command) {
 		p.symbol = ""
-		p.binaryCode = ""
+		p.instruction = nil
 
-		return
+		return nil
 	}
 
 	command = p.trimComment(command)
@@ -153,10 +247,15 @@ func (p *Parser) parseNextOnPhase1() {
 		// update label location
 		sym, err := p.parseSymbol(command)
 		if err != nil {
-			log.Fatalf(err.Error())
+			return err
+		}
+
+		if previous, exist := p.labels[sym]; exist {
+			return &LabelError{Label: sym, Previous: previous}
 		}
+		p.labels[sym] = p.Pos()
 
-		p.symbolTable.AddEntry(sym, p.lineCounter)
+		p.symbolTable.AddEntry(sym, p.lineCounter, symbol.LABEL)
 	} else if p.commandType == C_COMMAND {
 		p.symbol = ""
 
@@ -164,18 +263,20 @@ func (p *Parser) parseNextOnPhase1() {
 
 		p.lineCounter++
 	}
+
+	return nil
 }
 
-func (p *Parser) parseNextOnPhase2() {
-	command := p.scanner.Text()
+func (p *Parser) parseNextOnPhase2() error {
+	command := p.text
 
 	command = strings.TrimSpace(command)
 
 	if isAbleToSkip(command) {
 		p.symbol = ""
-		p.binaryCode = ""
+		p.instruction = nil
 
-		return
+		return nil
 	}
 
 	command = p.trimComment(command)
@@ -185,26 +286,18 @@ func (p *Parser) parseNextOnPhase2() {
 	if p.commandType == A_COMMAND {
 		sym, err := p.parseSymbol(command)
 		if err != nil {
-			log.Fatalf(err.Error())
+			return err
 		}
 
-		_, err = strconv.Atoi(sym)
-		if err == nil {
-			// A-Instruction does not have a symbol
-
-			p.setBinaryCodeWhenAInstruction(command)
+		if !expr.IsSymbol(sym) {
+			// A-Instruction has a constant or an expression
 
-			return
+			return p.setInstructionWhenAInstruction(command)
 		}
 
-		address, isExist := p.symbolTable.GetAddress(sym)
-		if !isExist {
-			// If symbol is new variable, set variable to memory address
-			address = p.addressCounter
-
-			p.symbolTable.AddEntry(sym, address)
-
-			p.addressCounter++
+		address, err := p.address(sym)
+		if err != nil {
+			return err
 		}
 
 		p.setSymbol(command)
@@ -213,7 +306,7 @@ func (p *Parser) parseNextOnPhase2() {
 		p.comp = ""
 		p.jump = ""
 
-		p.binaryCode = binaryStringToByteArray(strconv.Itoa(address))
+		p.instruction = code.AInstruction{Value: uint16(address)}
 	} else if p.commandType == L_COMMAND {
 		p.setSymbol(command)
 
@@ -221,18 +314,20 @@ func (p *Parser) parseNextOnPhase2() {
 		p.comp = ""
 		p.jump = ""
 
-		p.binaryCode = ""
+		p.instruction = nil
 	} else if p.commandType == C_COMMAND {
 		p.symbol = ""
 
 		p.setDestCompJumpWhenCInstruction(command)
 
-		p.setBinaryCodeWhenCInstruction()
+		return p.setInstructionWhenCInstruction()
 	}
+
+	return nil
 }
 
 func (p *Parser) trimComment(command string) string {
-	return strings.TrimSpace(strings.Split(command, "//")[0])
+	return strings.TrimSpace(source.Code(command))
 }
 
 func (p *Parser) setCommandType(command string) {
@@ -255,7 +350,7 @@ func (p *Parser) parseSymbol(command string) (string, error) {
 		return command[1 : len(command)-1], nil
 	}
 
-	return "", fmt.Errorf("can't parse symbol: %s", command)
+	return "", &SyntaxError{Command: command, Msg: "can't parse symbol"}
 }
 
 func (p *Parser) setSymbol(command string) {
@@ -268,19 +363,77 @@ func (p *Parser) setSymbol(command string) {
 	}
 }
 
-func (p *Parser) setBinaryCodeWhenAInstruction(command string) {
+func (p *Parser) setInstructionWhenAInstruction(command string) error {
 	command = command[1:]
 
-	p.binaryCode = binaryStringToByteArray(command)
-}
+	e, err := expr.Parse(command)
+	if err != nil {
+		var exprErr *expr.Error
+		if !errors.As(err, &exprErr) {
+			return &ExpressionError{Expr: command, Msg: err.Error()}
+		}
+		if exprErr.Literal != "" {
+			return &NumberError{Literal: exprErr.Literal, Err: strconv.ErrSyntax}
+		}
+
+		return &ExpressionError{Expr: command, Offset: exprErr.Offset, Msg: exprErr.Msg}
+	}
 
-func binaryStringToByteArray(command string) string {
-	i, err := strconv.Atoi(command)
+	value, err := expr.Eval(e, p.address)
+	if capacityErr, ok := err.(*CapacityError); ok {
+		return capacityErr
+	}
 	if err != nil {
-		log.Fatalf("Can't convert %s to integer", command)
+		return &ExpressionError{Expr: command, Msg: err.Error()}
+	}
+
+	if value < 0 || value > code.MaxAddress {
+		if !isLiteral(e) {
+			return &RangeError{Expr: command, Value: value}
+		}
+
+		// a literal is truncated like the CPU emulator does
+		value &= code.MaxAddress
+		p.warn(&LiteralError{Literal: command, Value: value})
+	}
+
+	p.symbol = command
+	p.instruction = code.AInstruction{Value: uint16(value)}
+
+	return nil
+}
+
+func isLiteral(e expr.Expr) bool {
+	if u, ok := e.(*expr.Unary); ok && u.Op == '-' {
+		e = u.Operand
 	}
+	_, ok := e.(*expr.Number)
 
-	return fmt.Sprintf("%016b", i)
+	return ok
+}
+
+// address returns the value of a symbol, allocating a new variable when the
+// symbol is not a label, a variable or an extern yet. A variable past the
+// limit is still allocated, so that it is reported only once.
+func (p *Parser) address(sym string) (int, error) {
+	address, isExist := p.symbolTable.GetAddress(sym)
+	if !isExist && p.externs[sym] {
+		return 0, nil
+	}
+	if !isExist {
+		// If symbol is new variable, set variable to memory address
+		address = p.addressCounter
+
+		p.symbolTable.AddEntry(sym, address, symbol.VARIABLE)
+
+		p.addressCounter++
+
+		if address >= p.variableLimit {
+			return address, &CapacityError{Symbol: sym, Limit: p.variableLimit}
+		}
+	}
+
+	return address, nil
 }
 
 func (p *Parser) setDestCompJumpWhenCInstruction(command string) {
@@ -290,18 +443,23 @@ func (p *Parser) setDestCompJumpWhenCInstruction(command string) {
 		jumpMnemonic string
 	)
 
-	if strings.Contains(command, "=") {
-		s := strings.Split(command, "=")
-		destMnemonic = s[0]
-		command = s[1]
+	// command is the text without its indentation and comment
+	at := len(p.text) - len(strings.TrimLeft(p.text, " \t"))
+	p.destAt, p.compAt, p.jumpAt = at, at, at
+
+	if i := strings.Index(command, "="); i >= 0 {
+		destMnemonic = command[:i]
+		command = command[i+1:]
+		p.compAt += i + 1
 	}
 
-	if strings.Contains(command, ";") {
-		s := strings.Split(command, ";")
-		compMnemonic = s[0]
-		jumpMnemonic = s[1]
+	if i := strings.Index(command, ";"); i >= 0 {
+		compMnemonic = command[:i]
+		jumpMnemonic = command[i+1:]
+		p.jumpAt = p.compAt + i + 1
 	} else {
 		compMnemonic = command
+		p.jumpAt = p.compAt + len(command)
 	}
 
 	p.dest = destMnemonic
@@ -309,36 +467,113 @@ func (p *Parser) setDestCompJumpWhenCInstruction(command string) {
 	p.jump = jumpMnemonic
 }
 
-func (p *Parser) setBinaryCodeWhenCInstruction() {
-	dest, ok := code.Dest(p.dest)
+// mnemonicErrors holds every bad field of a single C-Instruction.
+type mnemonicErrors []*MnemonicError
+
+func (m mnemonicErrors) Error() string {
+	return m[0].Error()
+}
+
+// setInstructionWhenCInstruction checks the fields of a C-Instruction and
+// rewrites them to the spelling of the code tables, so that D = M + 1,
+// DM=A+D and MD=D+A all give the same instruction.
+func (p *Parser) setInstructionWhenCInstruction() error {
+	var errs mnemonicErrors
+
+	dest, ok := code.NormalizeDest(p.dest)
 	if !ok {
-		if p.dest != "" {
-			log.Fatalf("Can't find dest mnemonic: %+v", p.dest)
-		} else {
-			dest, _ = code.Dest("null0")
-		}
+		errs = append(errs, &MnemonicError{Field: "dest", Mnemonic: strings.TrimSpace(p.dest), Hint: "use each of A, D and M at most once"})
 	}
 
-	comp, ok := code.Comp(p.comp)
-	if !ok && p.comp != "" {
-		log.Fatalf("Can't find comp mnemonic: %+v, phase: %d", p.comp, p.phase)
+	comp, ok := code.NormalizeComp(p.comp)
+	if !ok {
+		errs = append(errs, &MnemonicError{Field: "comp", Mnemonic: strings.TrimSpace(p.comp), Hint: compHint(p.comp)})
 	}
 
-	jump, ok := code.Jump(p.jump)
+	jump, ok := code.NormalizeJump(p.jump)
 	if !ok {
-		if p.jump != "" {
-			log.Fatalf("Can't find jump mnemonic: %+v", p.jump)
-		} else {
-			jump, _ = code.Jump("null")
-		}
+		errs = append(errs, &MnemonicError{Field: "jump", Mnemonic: strings.TrimSpace(p.jump), Hint: "use JGT, JEQ, JGE, JLT, JNE, JLE or JMP"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	p.dest = dest
+	p.comp = comp
+	p.jump = jump
+
+	if code.IsExtended(comp) && !p.extended {
+		p.warn(&ExtendedError{Command: strings.TrimSpace(p.text)})
+	}
+
+	p.instruction = code.CInstruction{Dest: p.dest, Comp: p.comp, Jump: p.jump}
+
+	return nil
+}
+
+func compHint(comp string) string {
+	comp = strings.TrimSpace(comp)
+
+	switch {
+	case comp == "":
+		return "a computation is required"
+	case strings.Contains(comp, "A") && strings.Contains(comp, "M"):
+		return "A and M can't be used in the same computation"
+	case strings.Contains(comp, "-") && strings.Index(comp, "-") > 0:
+		return "the operands of - can't be swapped"
 	}
 
-	binaryCode := "111"
-	binaryCode += comp
-	binaryCode += dest
-	binaryCode += jump
+	return "not a computation of the Hack ALU"
+}
+
+func (p *Parser) warn(err error) {
+	w := p.errorAt(err)
+	w.Warning = true
+
+	p.warnings = append(p.warnings, w)
+}
+
+func (p *Parser) errorAt(err error) *Error {
+	var (
+		text   string
+		offset int
+	)
+	switch e := err.(type) {
+	case *MnemonicError:
+		text = e.Mnemonic
+	case *NumberError:
+		text = e.Literal
+	case *SyntaxError:
+		text = e.Command
+	case *ExpressionError:
+		text = e.Expr
+		offset = e.Offset
+	case *RangeError:
+		text = e.Expr
+	case *ExtendedError:
+		text = e.Command
+	case *LiteralError:
+		text = e.Literal
+	case *CapacityError:
+		text = e.Symbol
+	case *LabelError:
+		text = e.Label
+	}
+
+	pos := p.Pos()
+	if e, ok := err.(*MnemonicError); ok {
+		// the same text can be in more than one field, like in D=D;D
+		at := map[string]int{"dest": p.destAt, "comp": p.compAt, "jump": p.jumpAt}[e.Field]
+		field := p.text[at:]
+		pos.Column = at + len(field) - len(strings.TrimLeft(field, " \t")) + 1
+	} else if i := strings.Index(p.text, text); text != "" && i >= 0 {
+		pos.Column = i + offset + 1
+	} else {
+		pos.Column = len(p.text) - len(strings.TrimLeft(p.text, " \t")) + 1
+	}
 
-	p.binaryCode = binaryCode
+	return &Error{Pos: pos, Text: text, Err: err}
 }
 
 func isAbleToSkip(command string) bool {
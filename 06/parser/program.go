@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"assembler/code"
+	"assembler/source"
+	"assembler/symbol"
+	"strings"
+)
+
+// Statement is one label or instruction of a program, as the parser saw it
+// in phase 2.
+type Statement struct {
+	Pos         Position
+	Text        string // the command without its comment
+	CommandType COMMAND_TYPE
+	Symbol      string // label of an L_COMMAND, operand of an A_COMMAND
+	Address     int    // ROM address of the instruction, or of the next one for labels
+	Instruction code.Instruction
+}
+
+// Parse preprocesses and assembles lines like AssembleLines, but returns
+// the labels and instructions of the program instead of machine words, for
+// tools that look at a program as a whole.
+func Parse(lines []source.Line, opts Options) ([]Statement, *symbol.SymbolTable, error) {
+	expanded, err := Preprocess(lines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	code, _ := splitLinkage(expanded)
+
+	var program []Statement
+	_, p, err := assemble(code, opts, func(p *Parser, address int) error {
+		text := strings.TrimSpace(source.Code(p.text))
+		if text == "" {
+			return nil
+		}
+
+		pos := p.Pos()
+		pos.Column = strings.Index(p.text, text) + 1
+
+		s := Statement{
+			Pos:         pos,
+			Text:        text,
+			CommandType: p.CommandType(),
+			Address:     address,
+			Instruction: p.Instruction(),
+		}
+		if s.CommandType != C_COMMAND {
+			s.Symbol, _ = p.Symbol()
+		}
+		program = append(program, s)
+
+		return nil
+	})
+	if p == nil {
+		return nil, nil, err
+	}
+
+	return program, p.SymbolTable(), err
+}
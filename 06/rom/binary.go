@@ -0,0 +1,23 @@
+package rom
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// binaryWriter writes every word as two bytes, most significant byte first.
+type binaryWriter struct{}
+
+func (binaryWriter) Extension() string {
+	return "bin"
+}
+
+func (binaryWriter) Write(w io.Writer, words []uint16) error {
+	writer := bufio.NewWriter(w)
+	if err := binary.Write(writer, binary.BigEndian, words); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
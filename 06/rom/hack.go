@@ -0,0 +1,55 @@
+package rom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadHack reads a .hack file: one 16 character binary word per line.
+// Blank lines are ignored.
+func ReadHack(r io.Reader) ([]uint16, error) {
+	words := make([]uint16, 0)
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if len(text) != 16 {
+			return nil, fmt.Errorf("line %d: expected 16 binary digits, got %q", line, text)
+		}
+
+		word, err := strconv.ParseUint(text, 2, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: can't parse %q as binary", line, text)
+		}
+
+		words = append(words, uint16(word))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
+
+// WriteHack writes words in the .hack format read by ReadHack.
+func WriteHack(w io.Writer, words []uint16) error {
+	writer := bufio.NewWriter(w)
+	for _, word := range words {
+		if _, err := fmt.Fprintf(writer, "%016b\n", word); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,58 @@
+package rom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+const intelHexRecordSize = 16 // data bytes per record
+
+// intelHexWriter writes an Intel HEX file of data records followed by an
+// end of file record. Addresses count bytes and every word is stored most
+// significant byte first, so the 32K word ROM fits in 16 bit addresses.
+type intelHexWriter struct{}
+
+func (intelHexWriter) Extension() string {
+	return "hex"
+}
+
+func (intelHexWriter) Write(w io.Writer, words []uint16) error {
+	data := make([]byte, 0, len(words)*2)
+	for _, word := range words {
+		data = append(data, byte(word>>8), byte(word))
+	}
+
+	writer := bufio.NewWriter(w)
+	for address := 0; address < len(data); address += intelHexRecordSize {
+		end := address + intelHexRecordSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		if err := writeIntelHexRecord(writer, uint16(address), 0x00, data[address:end]); err != nil {
+			return err
+		}
+	}
+
+	if err := writeIntelHexRecord(writer, 0, 0x01, nil); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+func writeIntelHexRecord(w io.Writer, address uint16, recordType byte, data []byte) error {
+	sum := byte(len(data)) + byte(address>>8) + byte(address) + recordType
+
+	record := fmt.Sprintf(":%02X%04X%02X", len(data), address, recordType)
+	for _, b := range data {
+		record += fmt.Sprintf("%02X", b)
+		sum += b
+	}
+	record += fmt.Sprintf("%02X\n", -sum)
+
+	_, err := io.WriteString(w, record)
+
+	return err
+}
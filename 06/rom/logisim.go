@@ -0,0 +1,37 @@
+package rom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+const logisimWordsPerLine = 8
+
+// logisimWriter writes the "v2.0 raw" image that Logisim loads into ROM
+// and RAM components: a header line followed by hexadecimal words.
+type logisimWriter struct{}
+
+func (logisimWriter) Extension() string {
+	return "rom"
+}
+
+func (logisimWriter) Write(w io.Writer, words []uint16) error {
+	writer := bufio.NewWriter(w)
+	if _, err := writer.WriteString("v2.0 raw\n"); err != nil {
+		return err
+	}
+
+	for i, word := range words {
+		separator := " "
+		if (i+1)%logisimWordsPerLine == 0 || i == len(words)-1 {
+			separator = "\n"
+		}
+
+		if _, err := fmt.Fprintf(writer, "%x%s", word, separator); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
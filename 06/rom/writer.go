@@ -0,0 +1,54 @@
+package rom
+
+import (
+	"io"
+	"sort"
+)
+
+// Writer serializes assembled words into one ROM image format.
+type Writer interface {
+	// Extension is the file extension of the format, without the dot.
+	Extension() string
+	Write(w io.Writer, words []uint16) error
+}
+
+var writers = map[string]Writer{}
+
+// Register makes a Writer available under format. Registering the same
+// format twice replaces the earlier Writer.
+func Register(format string, writer Writer) {
+	writers[format] = writer
+}
+
+func Lookup(format string) (Writer, bool) {
+	writer, ok := writers[format]
+	return writer, ok
+}
+
+// Formats returns every registered format, sorted.
+func Formats() []string {
+	formats := make([]string, 0, len(writers))
+	for format := range writers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	return formats
+}
+
+func init() {
+	Register("hack", hackWriter{})
+	Register("bin", binaryWriter{})
+	Register("ihex", intelHexWriter{})
+	Register("logisim", logisimWriter{})
+}
+
+type hackWriter struct{}
+
+func (hackWriter) Extension() string {
+	return "hack"
+}
+
+func (hackWriter) Write(w io.Writer, words []uint16) error {
+	return WriteHack(w, words)
+}
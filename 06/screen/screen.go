@@ -0,0 +1,86 @@
+package screen
+
+import (
+	"assembler/emulator"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+)
+
+const (
+	Width  = 512
+	Height = 256
+
+	wordsPerRow = Width / 16
+)
+
+// palette draws pixels that are 0 white and pixels that are 1 black, like
+// the screen of the CPU emulator.
+var palette = color.Palette{color.White, color.Black}
+
+// Pixel reports whether the pixel at x, y is black. The pixels of a row
+// are 16 to a word, the leftmost one in the least significant bit.
+func Pixel(c *emulator.Computer, x int, y int) bool {
+	word := c.RAM[emulator.SCREEN+y*wordsPerRow+x/16]
+
+	return word&(1<<uint(x%16)) != 0
+}
+
+// Image returns the screen of c as a 512x256 image.
+func Image(c *emulator.Computer) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, Width, Height), palette)
+
+	for y := 0; y < Height; y++ {
+		row := img.Pix[y*img.Stride:]
+		for i := 0; i < wordsPerRow; i++ {
+			word := c.RAM[emulator.SCREEN+y*wordsPerRow+i]
+			if word == 0 {
+				continue
+			}
+
+			for bit := 0; bit < 16; bit++ {
+				row[i*16+bit] = uint8(word >> uint(bit) & 1)
+			}
+		}
+	}
+
+	return img
+}
+
+// WritePNG writes the screen of c to w as a PNG image.
+func WritePNG(w io.Writer, c *emulator.Computer) error {
+	return png.Encode(w, Image(c))
+}
+
+// Recorder collects screens of a running computer as the frames of an
+// animated GIF.
+type Recorder struct {
+	// Delay is the time each frame is shown, in 100ths of a second.
+	Delay int
+
+	anim gif.GIF
+}
+
+// NewRecorder makes a Recorder that shows every frame for delay 100ths of
+// a second.
+func NewRecorder(delay int) *Recorder {
+	return &Recorder{Delay: delay}
+}
+
+// Add adds the screen of c as the next frame.
+func (r *Recorder) Add(c *emulator.Computer) {
+	r.anim.Image = append(r.anim.Image, Image(c))
+	r.anim.Delay = append(r.anim.Delay, r.Delay)
+}
+
+// Frames returns the number of frames added so far.
+func (r *Recorder) Frames() int {
+	return len(r.anim.Image)
+}
+
+// Encode writes the frames to w as a GIF that loops forever.
+func (r *Recorder) Encode(w io.Writer) error {
+	return gif.EncodeAll(w, &r.anim)
+}
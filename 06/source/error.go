@@ -0,0 +1,45 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a problem a preprocessor found on Line. Column starts at 1.
+type Error struct {
+	Line   Line
+	Column int
+	Text   string
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Errorf makes an Error pointing at the first occurrence of text in line.
+func Errorf(line Line, text string, format string, args ...interface{}) *Error {
+	column := strings.Index(line.Text, text) + 1
+	if column == 0 {
+		column = 1
+	}
+
+	return &Error{
+		Line:   line,
+		Column: column,
+		Text:   text,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// Errors is every Error a preprocessor found, in source order.
+type Errors []*Error
+
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = fmt.Sprintf("%s:%d:%d: %s", e.Line.File, e.Line.Line, e.Column, e.Msg)
+	}
+
+	return strings.Join(msgs, "\n")
+}
@@ -0,0 +1,113 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Include replaces every
+//
+//	.include "file.asm"
+//
+// line with the lines of that file. The file name is relative to the
+// directory of the file holding the directive. A file that includes itself,
+// directly or through other files, is reported as an include cycle.
+func Include(lines []Line) ([]Line, error) {
+	if !HasDirective(lines, ".include") {
+		return lines, nil
+	}
+
+	i := &includer{out: make([]Line, 0, len(lines))}
+
+	i.include(lines, nil)
+
+	if len(i.errs) > 0 {
+		return nil, i.errs
+	}
+
+	return i.out, nil
+}
+
+type includer struct {
+	out  []Line
+	errs Errors
+}
+
+// include appends lines to the output. stack holds the absolute paths of
+// the files that are being included, outermost first, and is nil for the
+// files given to Include.
+func (i *includer) include(lines []Line, stack []string) {
+	for _, line := range lines {
+		if FirstWord(line.Text) != ".include" {
+			i.out = append(i.out, line)
+			continue
+		}
+
+		code := strings.TrimSpace(Code(line.Text))
+		if !strings.HasPrefix(code, ".include") {
+			i.out = append(i.out, line)
+			continue
+		}
+
+		arg := strings.TrimSpace(strings.TrimPrefix(code, ".include"))
+		if len(arg) < 2 || arg[0] != '"' || arg[len(arg)-1] != '"' {
+			i.errs = append(i.errs, Errorf(line, ".include", ".include needs a quoted file name"))
+			continue
+		}
+
+		name := arg[1 : len(arg)-1]
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(line.File), name)
+		}
+
+		path, err := filepath.Abs(name)
+		if err != nil {
+			i.errs = append(i.errs, Errorf(line, arg, "can't include %s: %v", name, err))
+			continue
+		}
+
+		chain := stack
+		if chain == nil {
+			from, _ := filepath.Abs(line.File)
+			chain = []string{from}
+		}
+
+		if cycle := cycleOf(chain, path); cycle != "" {
+			i.errs = append(i.errs, Errorf(line, arg, "include cycle: %s", cycle))
+			continue
+		}
+
+		file, err := os.Open(name)
+		if err != nil {
+			i.errs = append(i.errs, Errorf(line, arg, "can't include %s: %v", name, err))
+			continue
+		}
+
+		included, err := ReadLines(file, name)
+		file.Close()
+		if err != nil {
+			i.errs = append(i.errs, Errorf(line, arg, "can't include %s: %v", name, err))
+			continue
+		}
+
+		i.include(included, append(chain[:len(chain):len(chain)], path))
+	}
+}
+
+// cycleOf returns the files of the cycle that including path at the end of
+// chain would close, and "" when there is none.
+func cycleOf(chain []string, path string) string {
+	for n, p := range chain {
+		if p == path {
+			names := append(chain[n:len(chain):len(chain)], path)
+			for k := range names {
+				names[k] = filepath.Base(names[k])
+			}
+
+			return strings.Join(names, " -> ")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,119 @@
+package source
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// Line is one line of assembly together with where it was written, so that
+// preprocessors can move lines around and errors still point at the source.
+type Line struct {
+	Text string
+	File string
+	Line int
+}
+
+// Load reads the named files one after another, so that they share one
+// symbol table when they are assembled.
+func Load(names ...string) ([]Line, error) {
+	lines := make([]Line, 0)
+	for _, name := range names {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+
+		fileLines, err := ReadLines(file, name)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, fileLines...)
+	}
+
+	return lines, nil
+}
+
+// ReadLines reads every line of r. file is recorded in each Line. The
+// lines share the memory of one string holding the whole input, which keeps
+// large generated sources cheap to read.
+func ReadLines(r io.Reader, file string) ([]Line, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(data)
+	lines := make([]Line, 0, strings.Count(text, "\n")+1)
+	for len(text) > 0 {
+		line := text
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			line = text[:i]
+			text = text[i+1:]
+		} else {
+			text = ""
+		}
+
+		lines = append(lines, Line{Text: strings.TrimSuffix(line, "\r"), File: file, Line: len(lines) + 1})
+	}
+
+	return lines, nil
+}
+
+// WriteLines writes the text of lines to w, one per line.
+func WriteLines(w io.Writer, lines []Line) error {
+	writer := bufio.NewWriter(w)
+	for _, line := range lines {
+		if _, err := writer.WriteString(line.Text + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// Code returns text without its "//" comment.
+func Code(text string) string {
+	if i := strings.Index(text, "//"); i >= 0 {
+		return text[:i]
+	}
+
+	return text
+}
+
+// FirstWord returns the first whitespace separated word of text.
+func FirstWord(text string) string {
+	start := 0
+	for start < len(text) && (text[start] == ' ' || text[start] == '\t') {
+		start++
+	}
+
+	end := start
+	for end < len(text) && text[end] != ' ' && text[end] != '\t' {
+		end++
+	}
+
+	return text[start:end]
+}
+
+// HasDirective reports whether any of lines starts with one of directives,
+// so preprocessors can hand back sources without any directive untouched.
+func HasDirective(lines []Line, directives ...string) bool {
+	for _, line := range lines {
+		word := FirstWord(line.Text)
+		if word == "" || word[0] != '.' {
+			continue
+		}
+
+		for _, directive := range directives {
+			if word == directive {
+				return true
+			}
+		}
+	}
+
+	return false
+}
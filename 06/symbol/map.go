@@ -0,0 +1,101 @@
+package symbol
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type Kind string
+
+const (
+	PREDEFINED = Kind("predefined")
+	LABEL      = Kind("label")
+	VARIABLE   = Kind("variable")
+)
+
+// Entry is one line of a symbol map. Labels hold ROM addresses, the other
+// kinds hold RAM addresses.
+type Entry struct {
+	Name    string `json:"name"`
+	Address int    `json:"address"`
+	Kind    Kind   `json:"kind"`
+}
+
+// ReadMap reads a symbol map in the form "<kind> <name> <address>", one
+// entry per line. Lines starting with "//" are comments.
+func ReadMap(r io.Reader) ([]Entry, error) {
+	entries := make([]Entry, 0)
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "//") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected <kind> <name> <address>, got %q", line, text)
+		}
+
+		kind := Kind(fields[0])
+		if kind != PREDEFINED && kind != LABEL && kind != VARIABLE {
+			return nil, fmt.Errorf("line %d: unknown symbol kind %q", line, fields[0])
+		}
+
+		address, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: can't convert %q to address", line, fields[2])
+		}
+
+		entries = append(entries, Entry{Name: fields[1], Address: address, Kind: kind})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// WriteMap writes entries in the form read by ReadMap.
+func WriteMap(w io.Writer, entries []Entry) error {
+	writer := bufio.NewWriter(w)
+	for _, entry := range entries {
+		if _, err := fmt.Fprintf(writer, "%s %s %d\n", entry.Kind, entry.Name, entry.Address); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// WriteMapJSON writes entries as a JSON array of {name, address, kind}.
+func WriteMapJSON(w io.Writer, entries []Entry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(entries)
+}
+
+func ReadMapJSON(r io.Reader) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Kind != PREDEFINED && entry.Kind != LABEL && entry.Kind != VARIABLE {
+			return nil, fmt.Errorf("%s: unknown symbol kind %q", entry.Name, entry.Kind)
+		}
+	}
+
+	return entries, nil
+}
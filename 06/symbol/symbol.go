@@ -1,41 +1,70 @@
 package symbol
 
+import "sort"
+
+// Variables are allocated from FirstVariable up, and below
+// DefaultVariableLimit unless a limit is given: from SCREEN on RAM is
+// memory mapped.
+const (
+	FirstVariable        = 16
+	DefaultVariableLimit = 16384
+)
+
 type SymbolTable struct {
 	table map[string]int
+	kinds map[string]Kind
 }
 
 func New() *SymbolTable {
-	return &SymbolTable{
-		table: map[string]int{
-			"SP":     0,
-			"LCL":    1,
-			"ARG":    2,
-			"THIS":   3,
-			"THAT":   4,
-			"R0":     0,
-			"R1":     1,
-			"R2":     2,
-			"R3":     3,
-			"R4":     4,
-			"R5":     5,
-			"R6":     6,
-			"R7":     7,
-			"R8":     8,
-			"R9":     9,
-			"R10":    10,
-			"R11":    11,
-			"R12":    12,
-			"R13":    13,
-			"R14":    14,
-			"R15":    15,
-			"SCREEN": 16384,
-			"KBD":    24576,
-		},
+	st := &SymbolTable{
+		table: make(map[string]int, len(predefined)),
+		kinds: make(map[string]Kind, len(predefined)),
+	}
+
+	for symbol, address := range predefined {
+		st.AddEntry(symbol, address, PREDEFINED)
+	}
+
+	return st
+}
+
+var (
+	predefined = map[string]int{
+		"SP":     0,
+		"LCL":    1,
+		"ARG":    2,
+		"THIS":   3,
+		"THAT":   4,
+		"R0":     0,
+		"R1":     1,
+		"R2":     2,
+		"R3":     3,
+		"R4":     4,
+		"R5":     5,
+		"R6":     6,
+		"R7":     7,
+		"R8":     8,
+		"R9":     9,
+		"R10":    10,
+		"R11":    11,
+		"R12":    12,
+		"R13":    13,
+		"R14":    14,
+		"R15":    15,
+		"SCREEN": 16384,
+		"KBD":    24576,
 	}
+)
+
+func IsPredefined(symbol string) bool {
+	_, isExist := predefined[symbol]
+
+	return isExist
 }
 
-func (st *SymbolTable) AddEntry(symbol string, address int) {
+func (st *SymbolTable) AddEntry(symbol string, address int, kind Kind) {
 	st.table[symbol] = address
+	st.kinds[symbol] = kind
 }
 
 func (st *SymbolTable) Contains(symbol string) bool {
@@ -49,3 +78,33 @@ func (st *SymbolTable) GetAddress(symbol string) (int, bool) {
 
 	return address, isExist
 }
+
+func (st *SymbolTable) Kind(symbol string) (Kind, bool) {
+	kind, isExist := st.kinds[symbol]
+
+	return kind, isExist
+}
+
+// Entries returns every symbol of the table, ordered by kind, then by
+// address and then by name.
+func (st *SymbolTable) Entries() []Entry {
+	entries := make([]Entry, 0, len(st.table))
+	for symbol, address := range st.table {
+		entries = append(entries, Entry{Name: symbol, Address: address, Kind: st.kinds[symbol]})
+	}
+
+	order := map[Kind]int{PREDEFINED: 0, LABEL: 1, VARIABLE: 2}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+
+		return a.Name < b.Name
+	})
+
+	return entries
+}
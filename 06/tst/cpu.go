@@ -0,0 +1,159 @@
+package tst
+
+import (
+	"assembler/emulator"
+	"assembler/parser"
+	"assembler/rom"
+	"assembler/source"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cpu is the Simulator of CPU emulator scripts, an emulator.Computer that
+// counts the clock for the time column.
+type cpu struct {
+	computer *emulator.Computer
+
+	time int
+	half bool // between tick and tock
+}
+
+func newCPU() *cpu {
+	computer, _ := emulator.New(nil)
+
+	return &cpu{computer: computer}
+}
+
+func (c *cpu) Load(dir string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("load needs one .asm or .hack file")
+	}
+	name := filepath.Join(dir, args[0])
+
+	var (
+		words []uint16
+		err   error
+	)
+	switch filepath.Ext(name) {
+	case ".asm":
+		var lines []source.Line
+		lines, err = source.Load(name)
+		if err == nil {
+			words, _, err = parser.AssembleLines(lines, parser.Options{})
+		}
+	case ".hack":
+		words, err = readHack(name)
+	default:
+		return fmt.Errorf("can't load %s, only .asm and .hack files", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	c.time = 0
+	c.half = false
+
+	return c.computer.Load(words)
+}
+
+func readHack(name string) ([]uint16, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return rom.ReadHack(file)
+}
+
+func (c *cpu) Exec(command Command) (bool, error) {
+	switch command.Name {
+	case "ticktock":
+		c.half = false
+		c.time++
+		return true, c.computer.Step()
+	case "tick":
+		c.half = true
+		return true, c.computer.Step()
+	case "tock":
+		c.half = false
+		c.time++
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (c *cpu) Get(name string) (uint16, string, error) {
+	var value uint16
+	switch name {
+	case "time":
+		if c.half {
+			return uint16(c.time), strconv.Itoa(c.time) + "+", nil
+		}
+		return uint16(c.time), strconv.Itoa(c.time), nil
+	case "A":
+		value = c.computer.A
+	case "D":
+		value = c.computer.D
+	case "PC":
+		value = c.computer.PC
+	default:
+		memory, address, err := c.address(name)
+		if err != nil {
+			return 0, "", err
+		}
+		value = memory[address]
+	}
+
+	return value, strconv.Itoa(int(int16(value))), nil
+}
+
+func (c *cpu) Set(name string, value uint16) error {
+	switch name {
+	case "A":
+		c.computer.A = value
+	case "D":
+		c.computer.D = value
+	case "PC":
+		c.computer.PC = value
+	default:
+		address, err := index(name, "RAM", emulator.RAMSize)
+		if err != nil {
+			return err
+		}
+		c.computer.RAM[address] = value
+	}
+
+	return nil
+}
+
+// address returns the memory of a variable like RAM[256] or ROM[0], and
+// its address there.
+func (c *cpu) address(name string) ([]uint16, int, error) {
+	if strings.HasPrefix(name, "ROM[") {
+		address, err := index(name, "ROM", emulator.ROMSize)
+		return c.computer.ROM[:], address, err
+	}
+
+	address, err := index(name, "RAM", emulator.RAMSize)
+
+	return c.computer.RAM[:], address, err
+}
+
+// index reads the address of a memory variable like RAM[256].
+func index(name string, memory string, size int) (int, error) {
+	if !strings.HasPrefix(name, memory+"[") || !strings.HasSuffix(name, "]") {
+		return 0, fmt.Errorf("unknown variable %q", name)
+	}
+
+	address, err := strconv.Atoi(name[len(memory)+1 : len(name)-1])
+	if err != nil || address < 0 || address >= size {
+		return 0, fmt.Errorf("%q is not an address of %s", name, memory)
+	}
+
+	return address, nil
+}
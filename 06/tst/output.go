@@ -0,0 +1,104 @@
+package tst
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Column is one entry of output-list, like RAM[0]%D2.6.2: the value of
+// RAM[0] as a decimal number of width 6, with 2 spaces on each side.
+type Column struct {
+	Name     string
+	Format   byte // D, B, X or S
+	PadLeft  int
+	Len      int
+	PadRight int
+}
+
+// ParseColumn reads an output-list entry. The format is %D1.6.1 when it is
+// left out.
+func ParseColumn(s string) (Column, error) {
+	c := Column{Name: s, Format: 'D', PadLeft: 1, Len: 6, PadRight: 1}
+
+	i := strings.IndexByte(s, '%')
+	if i < 0 {
+		return c, nil
+	}
+	c.Name = s[:i]
+
+	spec := s[i+1:]
+	if spec == "" || strings.IndexByte("DBXS", spec[0]) < 0 {
+		return c, fmt.Errorf("%q has no format of D, B, X or S", s)
+	}
+	c.Format = spec[0]
+
+	parts := strings.Split(spec[1:], ".")
+	if len(parts) != 3 {
+		return c, fmt.Errorf("%q must end with padding.length.padding", s)
+	}
+
+	numbers := make([]int, 3)
+	for j, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return c, fmt.Errorf("%q must end with padding.length.padding", s)
+		}
+		numbers[j] = n
+	}
+	c.PadLeft, c.Len, c.PadRight = numbers[0], numbers[1], numbers[2]
+
+	return c, nil
+}
+
+func (c Column) width() int {
+	return c.PadLeft + c.Len + c.PadRight
+}
+
+// Header returns the name of the column centered in its width, cut to fit.
+func (c Column) Header() string {
+	name := c.Name
+	if len(name) > c.width() {
+		name = name[:c.width()]
+	}
+
+	left := (c.width() - len(name)) / 2
+
+	return strings.Repeat(" ", left) + name + strings.Repeat(" ", c.width()-left-len(name))
+}
+
+// Value formats value for the column. Numbers are right aligned and strings
+// left aligned, and both keep their rightmost digits when they don't fit.
+func (c Column) Value(value uint16, text string) string {
+	var s string
+	switch c.Format {
+	case 'D':
+		s = strconv.Itoa(int(int16(value)))
+	case 'B':
+		s = fmt.Sprintf("%016b", value)
+	case 'X':
+		s = fmt.Sprintf("%04X", value)
+	case 'S':
+		s = text
+		if len(s) > c.Len {
+			s = s[:c.Len]
+		}
+
+		return strings.Repeat(" ", c.PadLeft) + s + strings.Repeat(" ", c.Len-len(s)+c.PadRight)
+	}
+
+	if len(s) > c.Len {
+		s = s[len(s)-c.Len:]
+	}
+	fill := " "
+	if c.Format != 'D' {
+		fill = "0"
+	}
+
+	return strings.Repeat(" ", c.PadLeft) + strings.Repeat(fill, c.Len-len(s)) + s + strings.Repeat(" ", c.PadRight)
+}
+
+// Line joins the cells of an output line.
+func Line(cells []string) string {
+	return "|" + strings.Join(cells, "|") + "|"
+}
@@ -0,0 +1,387 @@
+package tst
+
+import (
+	"assembler/emulator"
+	"assembler/source"
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxIterations bounds while loops, so that a condition that never turns
+// false fails the script instead of running forever.
+const maxIterations = 1 << 20
+
+// Mismatch is the first line of the output that differs from the compare
+// file. Lines are counted from 1, the header included.
+type Mismatch struct {
+	Line int
+	Got  string
+	Want string
+}
+
+func (m *Mismatch) Error() string {
+	return fmt.Sprintf("comparison failure at line %d:\n  got  %s\n  want %s", m.Line, m.Got, m.Want)
+}
+
+// Simulator is what a script runs on, like the CPU emulator. Runner
+// handles the commands every script has, and leaves loading programs and
+// running them to the simulator.
+type Simulator interface {
+	// Load loads the program named by the arguments of a load command,
+	// with files relative to dir.
+	Load(dir string, args []string) error
+
+	// Exec runs a command of the simulator, like ticktock. It returns
+	// false when the simulator has no such command.
+	Exec(command Command) (bool, error)
+
+	// Get returns the value of a variable like RAM[0], and its decimal
+	// text in the output.
+	Get(name string) (uint16, string, error)
+
+	// Set sets a variable.
+	Set(name string, value uint16) error
+}
+
+// Runner runs test scripts on a Simulator.
+type Runner struct {
+	// Echo receives the messages of echo commands. They are dropped when
+	// it is nil.
+	Echo io.Writer
+
+	dir string
+	sim Simulator
+
+	columns []Column
+	out     *os.File
+	writer  *bufio.Writer
+	compare []string
+	lines   int // lines of output so far
+}
+
+// NewRunner makes a Runner for scripts of the CPU emulator whose files are
+// relative to dir.
+func NewRunner(dir string) *Runner {
+	return NewSimulatorRunner(dir, newCPU())
+}
+
+// NewSimulatorRunner makes a Runner for scripts that run on sim, whose files
+// are relative to dir.
+func NewSimulatorRunner(dir string, sim Simulator) *Runner {
+	return &Runner{dir: dir, sim: sim}
+}
+
+// Computer returns the computer the scripts run on, nil when they don't
+// run on the CPU emulator.
+func (r *Runner) Computer() *emulator.Computer {
+	if c, ok := r.sim.(*cpu); ok {
+		return c.computer
+	}
+
+	return nil
+}
+
+// RunFile runs the script in file on the CPU emulator, with the files it
+// names relative to its directory. A failed comparison is returned as a
+// *Mismatch.
+func RunFile(file string) error {
+	return RunSimulatorFile(file, newCPU())
+}
+
+// RunSimulatorFile is RunFile for scripts that run on sim.
+func RunSimulatorFile(file string, sim Simulator) error {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	commands, err := Parse(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	r := NewSimulatorRunner(filepath.Dir(file), sim)
+	r.Echo = os.Stdout
+
+	if err := r.Run(commands); err != nil {
+		if _, ok := err.(*Mismatch); ok {
+			return err
+		}
+
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	return nil
+}
+
+// Run executes commands, then closes the output file and checks that the
+// output was as long as the compare file.
+func (r *Runner) Run(commands []Command) error {
+	err := r.run(commands)
+
+	if r.out != nil {
+		if flushErr := r.writer.Flush(); err == nil {
+			err = flushErr
+		}
+		r.out.Close()
+	}
+
+	if err == nil && r.compare != nil && r.lines < len(r.compare) {
+		err = &Mismatch{Line: r.lines + 1, Got: "end of output", Want: r.compare[r.lines]}
+	}
+
+	return err
+}
+
+func (r *Runner) run(commands []Command) error {
+	for _, command := range commands {
+		if err := r.exec(command); err != nil {
+			if _, ok := err.(*Mismatch); ok {
+				return err
+			}
+
+			return fmt.Errorf("line %d: %v", command.Line, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) exec(command Command) error {
+	switch command.Name {
+	case "repeat":
+		for i := 0; i < command.Count; i++ {
+			if err := r.run(command.Body); err != nil {
+				return err
+			}
+		}
+	case "while":
+		for i := 0; ; i++ {
+			ok, err := r.check(command.Cond)
+			if err != nil || !ok {
+				return err
+			}
+			if i == maxIterations {
+				return fmt.Errorf("while loop ran %d times, its condition may never be false", maxIterations)
+			}
+
+			if err := r.run(command.Body); err != nil {
+				return err
+			}
+		}
+	case "load":
+		return r.sim.Load(r.dir, command.Args)
+	case "output-file":
+		return r.outputFile(command.Args)
+	case "compare-to":
+		return r.compareTo(command.Args)
+	case "output-list":
+		return r.outputList(command.Args)
+	case "output":
+		return r.output()
+	case "set":
+		return r.set(command.Args)
+	case "echo":
+		if r.Echo != nil {
+			fmt.Fprintln(r.Echo, strings.Join(command.Args, " "))
+		}
+	case "clear-echo":
+	default:
+		if ok, err := r.sim.Exec(command); ok || err != nil {
+			return err
+		}
+
+		return fmt.Errorf("unknown command %q", command.Name)
+	}
+
+	return nil
+}
+
+func (r *Runner) outputFile(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("output-file needs one file")
+	}
+
+	out, err := os.Create(filepath.Join(r.dir, args[0]))
+	if err != nil {
+		return err
+	}
+	r.out = out
+	r.writer = bufio.NewWriter(out)
+
+	return nil
+}
+
+func (r *Runner) compareTo(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("compare-to needs one file")
+	}
+
+	src, err := ioutil.ReadFile(filepath.Join(r.dir, args[0]))
+	if err != nil {
+		return err
+	}
+
+	lines, err := source.ReadLines(strings.NewReader(string(src)), args[0])
+	if err != nil {
+		return err
+	}
+
+	r.compare = make([]string, 0, len(lines))
+	for _, line := range lines {
+		if text := strings.TrimRight(line.Text, " \t"); text != "" {
+			r.compare = append(r.compare, text)
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) outputList(args []string) error {
+	r.columns = make([]Column, len(args))
+	headers := make([]string, len(args))
+	for i, arg := range args {
+		column, err := ParseColumn(arg)
+		if err != nil {
+			return err
+		}
+		r.columns[i] = column
+		headers[i] = column.Header()
+	}
+
+	return r.write(Line(headers))
+}
+
+func (r *Runner) output() error {
+	cells := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		value, text, err := r.sim.Get(column.Name)
+		if err != nil {
+			return err
+		}
+		cells[i] = column.Value(value, text)
+	}
+
+	return r.write(Line(cells))
+}
+
+// write writes one line of output and compares it to the compare file.
+// '*' in the compare file matches any character.
+func (r *Runner) write(line string) error {
+	if r.writer != nil {
+		if _, err := fmt.Fprintln(r.writer, line); err != nil {
+			return err
+		}
+	}
+	r.lines++
+
+	if r.compare == nil {
+		return nil
+	}
+
+	if r.lines > len(r.compare) {
+		return &Mismatch{Line: r.lines, Got: line, Want: "end of file"}
+	}
+	if want := r.compare[r.lines-1]; !matches(line, want) {
+		return &Mismatch{Line: r.lines, Got: line, Want: want}
+	}
+
+	return nil
+}
+
+func matches(got string, want string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for i := 0; i < len(got); i++ {
+		if got[i] != want[i] && want[i] != '*' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Runner) set(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("set needs a variable and a value")
+	}
+
+	value, err := ParseValue(args[1])
+	if err != nil {
+		return err
+	}
+
+	return r.sim.Set(args[0], value)
+}
+
+func (r *Runner) check(cond *Condition) (bool, error) {
+	left, err := r.operand(cond.Left)
+	if err != nil {
+		return false, err
+	}
+	right, err := r.operand(cond.Right)
+	if err != nil {
+		return false, err
+	}
+
+	switch cond.Op {
+	case "=":
+		return left == right, nil
+	case "<>":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "<=":
+		return left <= right, nil
+	default:
+		return left >= right, nil
+	}
+}
+
+// operand is a variable or a value in a condition, compared as a signed
+// 16 bit number.
+func (r *Runner) operand(s string) (int16, error) {
+	if value, err := ParseValue(s); err == nil {
+		return int16(value), nil
+	}
+
+	value, _, err := r.sim.Get(s)
+
+	return int16(value), err
+}
+
+// ParseValue reads a value of a script: a decimal number, or a number
+// written %D, %B or %X like %X4000.
+func ParseValue(s string) (uint16, error) {
+	base := 10
+	digits := s
+	if strings.HasPrefix(s, "%") && len(s) > 2 {
+		switch s[1] {
+		case 'D':
+		case 'B':
+			base = 2
+		case 'X':
+			base = 16
+		default:
+			return 0, fmt.Errorf("%q is not a value", s)
+		}
+		digits = s[2:]
+	}
+
+	value, err := strconv.ParseInt(digits, base, 32)
+	if err != nil || value < -32768 || value > 65535 {
+		return 0, fmt.Errorf("%q is not a 16 bit value", s)
+	}
+
+	return uint16(value), nil
+}
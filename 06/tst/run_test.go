@@ -0,0 +1,187 @@
+package tst
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// counter is a Simulator with one variable, x, that inc adds 1 to, and a
+// text variable, name.
+type counter struct {
+	x uint16
+}
+
+func (c *counter) Load(dir string, args []string) error {
+	c.x = 0
+
+	return nil
+}
+
+func (c *counter) Exec(command Command) (bool, error) {
+	switch command.Name {
+	case "inc":
+		c.x++
+		return true, nil
+	case "nop":
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (c *counter) Get(name string) (uint16, string, error) {
+	switch name {
+	case "x":
+		return c.x, fmt.Sprint(int16(c.x)), nil
+	case "name":
+		return 0, "abcdef", nil
+	}
+
+	return 0, "", fmt.Errorf("unknown variable %q", name)
+}
+
+func (c *counter) Set(name string, value uint16) error {
+	if name != "x" {
+		return fmt.Errorf("unknown variable %q", name)
+	}
+	c.x = value
+
+	return nil
+}
+
+// run runs script in a directory that holds the compare file cmp, and
+// returns the output file.
+func run(t *testing.T, script string, cmp string) (string, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.cmp"), []byte(cmp), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commands, err := Parse(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewSimulatorRunner(dir, &counter{}).Run(commands)
+
+	out, readErr := ioutil.ReadFile(filepath.Join(dir, "test.out"))
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	return string(out), err
+}
+
+const script = `
+load,
+output-file test.out,
+compare-to test.cmp,
+output-list x%D2.6.2 x%X1.4.1 x%B1.16.1 name%S1.4.1;
+
+set x 3;
+repeat 2 {
+    inc;
+}
+output;
+
+while x < 10 {
+    inc;
+}
+output;
+
+set x -3,
+output;
+`
+
+var want = []string{
+	"|    x     |  x   |        x         | name |",
+	"|       5  | 0005 | 0000000000000101 | abcd |",
+	"|      10  | 000A | 0000000000001010 | abcd |",
+	"|      -3  | FFFD | 1111111111111101 | abcd |",
+}
+
+func TestOutput(t *testing.T) {
+	cmp := strings.Join(want, "\n") + "\n"
+
+	out, err := run(t, script, cmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != cmp {
+		t.Errorf("got\n%s\nwant\n%s", out, cmp)
+	}
+}
+
+func TestCompareFailure(t *testing.T) {
+	cmp := want[0] + "\n" + want[1] + "\n" + strings.Replace(want[2], "10", "11", 1) + "\n" + want[3] + "\n"
+
+	_, err := run(t, script, cmp)
+	m, ok := err.(*Mismatch)
+	if !ok {
+		t.Fatalf("got %v, want a *Mismatch", err)
+	}
+	if m.Line != 3 || m.Got != want[2] {
+		t.Errorf("got a mismatch at line %d of %q, want line 3 of %q", m.Line, m.Got, want[2])
+	}
+}
+
+func TestCompareMatchesStars(t *testing.T) {
+	cmp := want[0] + "\n" + want[1] + "\n" + strings.Replace(want[2], "10", "**", 1) + "\n" + want[3] + "\n"
+
+	if _, err := run(t, script, cmp); err != nil {
+		t.Errorf("got %v, want * to match any character", err)
+	}
+}
+
+func TestCompareEndOfOutput(t *testing.T) {
+	cmp := strings.Join(want, "\n") + "\n" + want[3] + "\n"
+
+	_, err := run(t, script, cmp)
+	if m, ok := err.(*Mismatch); !ok || m.Line != 5 || m.Got != "end of output" {
+		t.Errorf("got %v, want a mismatch at line 5 for the end of output", err)
+	}
+}
+
+func TestWhileBound(t *testing.T) {
+	_, err := run(t, `
+output-file test.out;
+while x = 0 {
+    nop;
+}`, "")
+
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("while loop ran %d times", maxIterations)) {
+		t.Errorf("got %v, want an error that the while loop ran too often", err)
+	}
+	if err != nil && !strings.HasPrefix(err.Error(), "line 3: ") {
+		t.Errorf("got %q, want the line of the while loop", err)
+	}
+}
+
+func TestParseColumn(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Column
+	}{
+		{"RAM[0]%D2.6.2", Column{Name: "RAM[0]", Format: 'D', PadLeft: 2, Len: 6, PadRight: 2}},
+		{"time%S1.4.1", Column{Name: "time", Format: 'S', PadLeft: 1, Len: 4, PadRight: 1}},
+		{"PC", Column{Name: "PC", Format: 'D', PadLeft: 1, Len: 6, PadRight: 1}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseColumn(test.spec)
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %+v, %v, want %+v", test.spec, got, err, test.want)
+		}
+	}
+
+	for _, spec := range []string{"RAM[0]%Q1.6.1", "RAM[0]%D1.6", "RAM[0]%D1.x.1", "RAM[0]%"} {
+		if _, err := ParseColumn(spec); err == nil {
+			t.Errorf("%s: got no error", spec)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package tst
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Command is one command of a test script, with its arguments. Repeat and
+// while loops hold the commands of their body.
+type Command struct {
+	Line int
+	Name string
+	Args []string
+
+	Body  []Command
+	Count int        // times a repeat loop runs
+	Cond  *Condition // condition of a while loop
+}
+
+// Condition is the condition of a while loop, like RAM[0] <> 0.
+type Condition struct {
+	Left  string
+	Op    string
+	Right string
+}
+
+type token struct {
+	line int
+	text string
+	str  bool // a quoted string, without its quotes
+}
+
+// Parse reads the commands of a test script. Commands end with , or ; and
+// comments are written // or /* */.
+func Parse(src string) ([]Command, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &scriptParser{tokens: tokens}
+	commands, err := p.parseCommands()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+
+	return commands, nil
+}
+
+type scriptParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *scriptParser) parseCommands() ([]Command, error) {
+	var commands []Command
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		switch {
+		case t.text == "}" && !t.str:
+			return commands, nil
+		case (t.text == "," || t.text == ";") && !t.str:
+			p.pos++
+
+			continue
+		}
+
+		command, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+
+	return commands, nil
+}
+
+func (p *scriptParser) parseCommand() (Command, error) {
+	start := p.tokens[p.pos]
+	command := Command{Line: start.line, Name: start.text}
+	p.pos++
+
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		if !t.str && (t.text == "," || t.text == ";" || t.text == "}") {
+			break
+		}
+
+		if !t.str && t.text == "{" {
+			p.pos++
+
+			body, err := p.parseCommands()
+			if err != nil {
+				return command, err
+			}
+			if p.pos >= len(p.tokens) {
+				return command, fmt.Errorf("line %d: %s is missing its }", start.line, start.text)
+			}
+			p.pos++
+
+			command.Body = body
+
+			return command, p.parseLoop(&command)
+		}
+
+		command.Args = append(command.Args, t.text)
+		p.pos++
+	}
+
+	if command.Name == "repeat" || command.Name == "while" {
+		return command, fmt.Errorf("line %d: %s is missing its {", start.line, start.text)
+	}
+
+	return command, nil
+}
+
+func (p *scriptParser) parseLoop(command *Command) error {
+	switch command.Name {
+	case "repeat":
+		if len(command.Args) != 1 {
+			return fmt.Errorf("line %d: repeat needs a count, a headless run can't repeat forever", command.Line)
+		}
+
+		count, err := strconv.Atoi(command.Args[0])
+		if err != nil || count < 0 {
+			return fmt.Errorf("line %d: %q is not a repeat count", command.Line, command.Args[0])
+		}
+		command.Count = count
+	case "while":
+		cond, err := parseCondition(strings.Join(command.Args, " "))
+		if err != nil {
+			return fmt.Errorf("line %d: %v", command.Line, err)
+		}
+		command.Cond = cond
+	default:
+		return fmt.Errorf("line %d: %s can't have a body", command.Line, command.Name)
+	}
+
+	return nil
+}
+
+func parseCondition(s string) (*Condition, error) {
+	// longest operators first, so that <= is not read as <
+	for _, op := range []string{"<>", "<=", ">=", "=", "<", ">"} {
+		if i := strings.Index(s, op); i > 0 {
+			return &Condition{
+				Left:  strings.TrimSpace(s[:i]),
+				Op:    op,
+				Right: strings.TrimSpace(s[i+len(op):]),
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%q is not a condition", s)
+}
+
+func (p *scriptParser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if p.pos < len(p.tokens) {
+		line = p.tokens[p.pos].line
+	}
+
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case c == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			tokens = append(tokens, token{line: line, text: src[i+1 : i+1+end], str: true})
+			i += end + 2
+		case strings.IndexByte(",;{}", c) >= 0:
+			tokens = append(tokens, token{line: line, text: string(c)})
+			i++
+		default:
+			start := i
+			for i < len(src) && strings.IndexByte(" \t\r\n,;{}\"", src[i]) < 0 && !strings.HasPrefix(src[i:], "//") {
+				i++
+			}
+			tokens = append(tokens, token{line: line, text: src[start:i]})
+		}
+	}
+
+	return tokens, nil
+}
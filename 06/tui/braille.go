@@ -0,0 +1,59 @@
+package tui
+
+import (
+	"assembler/emulator"
+	"assembler/screen"
+)
+
+// dots are the bits of the braille dots of a cell, by row and column.
+var dots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// Braille draws the screen of c with braille characters, each one a cell
+// of 2x4 dots. A dot is scale x scale pixels and it is raised when any of
+// them is black, so that thin lines don't disappear when scaled down.
+func Braille(c *emulator.Computer, scale int) []string {
+	if scale < 1 {
+		scale = 1
+	}
+
+	cellWidth, cellHeight := 2*scale, 4*scale
+	columns := (screen.Width + cellWidth - 1) / cellWidth
+	rows := (screen.Height + cellHeight - 1) / cellHeight
+
+	lines := make([]string, rows)
+	cells := make([]rune, columns)
+	for row := 0; row < rows; row++ {
+		for column := range cells {
+			cell := rune(0x2800)
+			for dy := 0; dy < 4; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					if black(c, column*cellWidth+dx*scale, row*cellHeight+dy*scale, scale) {
+						cell |= dots[dy][dx]
+					}
+				}
+			}
+			cells[column] = cell
+		}
+		lines[row] = string(cells)
+	}
+
+	return lines
+}
+
+// black reports whether any pixel of the square at x, y is black.
+func black(c *emulator.Computer, x int, y int, size int) bool {
+	for py := y; py < y+size && py < screen.Height; py++ {
+		for px := x; px < x+size && px < screen.Width; px++ {
+			if screen.Pixel(c, px, py) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
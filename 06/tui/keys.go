@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"assembler/keyboard"
+)
+
+const (
+	ctrlC = 0x03
+	ctrlQ = 0x11
+	esc   = 0x1b
+)
+
+// tildeKeys are the keys of escape sequences like ESC [ 5 ~, by number.
+var tildeKeys = map[int]uint16{
+	1:  keyboard.HOME,
+	2:  keyboard.INSERT,
+	3:  keyboard.DELETE,
+	4:  keyboard.END,
+	5:  keyboard.PAGE_UP,
+	6:  keyboard.PAGE_DOWN,
+	7:  keyboard.HOME,
+	8:  keyboard.END,
+	11: keyboard.F1,
+	12: keyboard.F1 + 1,
+	13: keyboard.F1 + 2,
+	14: keyboard.F1 + 3,
+	15: keyboard.F1 + 4,
+	17: keyboard.F1 + 5,
+	18: keyboard.F1 + 6,
+	19: keyboard.F1 + 7,
+	20: keyboard.F1 + 8,
+	21: keyboard.F1 + 9,
+	23: keyboard.F1 + 10,
+	24: keyboard.F1 + 11,
+}
+
+// letterKeys are the keys of escape sequences like ESC [ A or ESC O P, by
+// their last letter.
+var letterKeys = map[byte]uint16{
+	'A': keyboard.UP,
+	'B': keyboard.DOWN,
+	'C': keyboard.RIGHT,
+	'D': keyboard.LEFT,
+	'H': keyboard.HOME,
+	'F': keyboard.END,
+	'P': keyboard.F1,
+	'Q': keyboard.F1 + 1,
+	'R': keyboard.F1 + 2,
+	'S': keyboard.F1 + 3,
+}
+
+// DecodeKeys turns what a terminal sent in raw mode into Hack key codes.
+// Bytes that are no Hack key are dropped. quit is true when Ctrl-C or
+// Ctrl-Q was pressed.
+func DecodeKeys(b []byte) (keys []uint16, quit bool) {
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == ctrlC || c == ctrlQ:
+			return keys, true
+		case c == '\r' || c == '\n':
+			keys = append(keys, keyboard.NEWLINE)
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, keyboard.BACKSPACE)
+		case c == esc:
+			key, n := escapeKey(b[i+1:])
+			if key != 0 {
+				keys = append(keys, key)
+			}
+			i += n
+		case keyboard.Printable(c):
+			keys = append(keys, uint16(c))
+		}
+	}
+
+	return keys, false
+}
+
+// escapeKey reads the escape sequence after ESC and returns its key and
+// length. ESC on its own is the escape key.
+func escapeKey(b []byte) (uint16, int) {
+	if len(b) < 2 || (b[0] != '[' && b[0] != 'O') {
+		return keyboard.ESC, 0
+	}
+
+	// parameters, then a final byte from @ to ~. Only the first parameter
+	// counts, the others are modifiers like shift.
+	n, end, first := 0, 1, true
+	for end < len(b) && b[end] >= '0' && b[end] <= ';' {
+		if b[end] < '0' || b[end] > '9' {
+			first = false
+		} else if first {
+			n = n*10 + int(b[end]-'0')
+		}
+		end++
+	}
+	if end == len(b) {
+		return 0, len(b)
+	}
+
+	final := b[end]
+	if final == '~' {
+		return tildeKeys[n], end + 1
+	}
+
+	return letterKeys[final], end + 1
+}
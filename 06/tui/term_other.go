@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package tui
+
+import (
+	"errors"
+)
+
+var errUnsupported = errors.New("the terminal UI needs linux")
+
+// MakeRaw is only supported on linux.
+func MakeRaw(fd int) (func() error, error) {
+	return nil, errUnsupported
+}
+
+// Size is only supported on linux.
+func Size(fd int) (int, int, error) {
+	return 0, 0, errUnsupported
+}
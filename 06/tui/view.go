@@ -0,0 +1,109 @@
+package tui
+
+import (
+	"assembler/emulator"
+	"assembler/screen"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PaneWidth is the width of the panes beside the screen.
+const PaneWidth = 28
+
+// View lays out the screen of a computer and the panes of its registers,
+// current instruction and watched RAM.
+type View struct {
+	Scale int
+	Watch []int // RAM addresses to show
+}
+
+// FitScale returns the smallest scale at which the screen and the panes fit
+// in a terminal of columns x rows. It is at least 1, even when nothing fits.
+func FitScale(columns int, rows int) int {
+	for scale := 1; scale < 8; scale++ {
+		width := screen.Width/(2*scale) + 3 + PaneWidth
+		height := screen.Height/(4*scale) + 2
+		if width <= columns && height <= rows {
+			return scale
+		}
+	}
+
+	return 8
+}
+
+// Render returns the lines of the view, with status on the last line of
+// the panes.
+func (v *View) Render(c *emulator.Computer, status string) []string {
+	pixels := Braille(c, v.Scale)
+	width := utf8.RuneCountInString(pixels[0])
+
+	left := make([]string, 0, len(pixels)+2)
+	left = append(left, "┌"+strings.Repeat("─", width)+"┐")
+	for _, line := range pixels {
+		left = append(left, "│"+line+"│")
+	}
+	left = append(left, "└"+strings.Repeat("─", width)+"┘")
+
+	right := v.panes(c, status)
+
+	lines := make([]string, 0, len(left)+len(right))
+	for i := 0; i < len(left) || i < len(right); i++ {
+		line := strings.Repeat(" ", width+2)
+		if i < len(left) {
+			line = left[i]
+		}
+		if i < len(right) {
+			line += " " + right[i]
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func (v *View) panes(c *emulator.Computer, status string) []string {
+	lines := []string{
+		"Registers",
+		fmt.Sprintf("  A  %6d", int16(c.A)),
+		fmt.Sprintf("  D  %6d", int16(c.D)),
+		fmt.Sprintf("  PC %6d", c.PC),
+		fmt.Sprintf("  cycles %d", c.Cycles),
+		"",
+		"Instruction",
+	}
+
+	if inst, err := c.Instruction(); err == nil {
+		lines = append(lines, fmt.Sprintf("  ROM[%d] %s", c.PC, inst))
+	} else {
+		lines = append(lines, fmt.Sprintf("  ROM[%d] invalid", c.PC))
+	}
+
+	if len(v.Watch) > 0 {
+		lines = append(lines, "", "RAM")
+		for _, address := range v.Watch {
+			lines = append(lines, fmt.Sprintf("  %-10s %6d", ramName(address), int16(c.RAM[address])))
+		}
+	}
+
+	lines = append(lines, "", status)
+
+	for i, line := range lines {
+		if len(line) > PaneWidth-1 {
+			lines[i] = line[:PaneWidth-1]
+		}
+	}
+
+	return lines
+}
+
+func ramName(address int) string {
+	switch address {
+	case emulator.KBD:
+		return "KBD"
+	case emulator.SCREEN:
+		return "SCREEN"
+	}
+
+	return fmt.Sprintf("RAM[%d]", address)
+}
@@ -0,0 +1,114 @@
+package verify
+
+import (
+	"assembler/code"
+	"assembler/parser"
+	"assembler/rom"
+	"assembler/source"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Mismatch is the first address where an assembled program differs from
+// the expected one. Got or Want is nil when that program ended before
+// Address.
+type Mismatch struct {
+	Address int
+	Got     *uint16
+	Want    *uint16
+}
+
+func (m *Mismatch) Error() string {
+	return fmt.Sprintf("address %d differs:\n  got  %s\n  want %s", m.Address, describe(m.Got), describe(m.Want))
+}
+
+func describe(word *uint16) string {
+	if word == nil {
+		return "end of program"
+	}
+
+	inst, err := code.Decode(*word)
+	if err != nil {
+		return fmt.Sprintf("%016b (not an instruction)", *word)
+	}
+
+	return fmt.Sprintf("%016b %s", *word, inst)
+}
+
+// Compare returns nil when got and want are the same program, and a
+// *Mismatch at the first address where they differ otherwise.
+func Compare(got []uint16, want []uint16) error {
+	for address := 0; address < len(got) || address < len(want); address++ {
+		m := &Mismatch{Address: address}
+		if address < len(got) {
+			m.Got = &got[address]
+		}
+		if address < len(want) {
+			m.Want = &want[address]
+		}
+
+		if m.Got == nil || m.Want == nil || *m.Got != *m.Want {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// File assembles asm and compares the result to expected, which is either
+// a .hack file or another .asm file to assemble.
+func File(asm string, expected string) error {
+	got, err := Assemble(asm)
+	if err != nil {
+		return err
+	}
+
+	return Expected(got, expected)
+}
+
+// Expected compares got to expected, which is either a .hack file or an
+// .asm file to assemble.
+func Expected(got []uint16, expected string) error {
+	var (
+		want []uint16
+		err  error
+	)
+	if strings.HasSuffix(expected, ".asm") {
+		want, err = Assemble(expected)
+	} else {
+		want, err = readHack(expected)
+	}
+	if err != nil {
+		return err
+	}
+
+	return Compare(got, want)
+}
+
+// Assemble assembles the files named like the assembler does.
+func Assemble(names ...string) ([]uint16, error) {
+	lines, err := source.Load(names...)
+	if err != nil {
+		return nil, err
+	}
+
+	words, _, err := parser.AssembleLines(lines, parser.Options{})
+
+	return words, err
+}
+
+func readHack(name string) ([]uint16, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	words, err := rom.ReadHack(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return words, nil
+}
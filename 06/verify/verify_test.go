@@ -0,0 +1,57 @@
+package verify
+
+import (
+	"testing"
+)
+
+func TestSamples(t *testing.T) {
+	tests := []struct {
+		asm      string
+		expected string
+	}{
+		{"../add/Add.asm", "../../05/Add.hack"},
+		{"../max/Max.asm", "../../05/Max.hack"},
+		{"../max/MaxL.asm", "../../05/Max.hack"},
+		{"../rect/Rect.asm", "../../05/Rect.hack"},
+		{"../rect/RectL.asm", "../../05/Rect.hack"},
+		{"../max/Max.asm", "../max/MaxL.asm"},
+		{"../rect/Rect.asm", "../rect/RectL.asm"},
+		{"../pong/Pong.asm", "../pong/PongL.asm"},
+	}
+
+	for _, test := range tests {
+		if err := File(test.asm, test.expected); err != nil {
+			t.Errorf("%s against %s: %v", test.asm, test.expected, err)
+		}
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	err := File("../max/Max.asm", "../rect/Rect.asm")
+
+	m, ok := err.(*Mismatch)
+	if !ok {
+		t.Fatalf("Max.asm against Rect.asm: got %v, want a *Mismatch", err)
+	}
+
+	// both start with @0 D=M
+	if m.Address != 2 {
+		t.Errorf("got first difference at %d, want 2", m.Address)
+	}
+	if m.Got == nil || m.Want == nil {
+		t.Errorf("got a missing instruction at %d: %v", m.Address, m)
+	}
+}
+
+func TestCompareLength(t *testing.T) {
+	err := Compare([]uint16{0, 1}, []uint16{0})
+
+	m, ok := err.(*Mismatch)
+	if !ok || m.Address != 1 || m.Want != nil {
+		t.Fatalf("got %v, want a mismatch at 1 with the expected program ended", err)
+	}
+
+	if err := Compare([]uint16{0xEC10}, []uint16{0xEC10}); err != nil {
+		t.Errorf("equal programs: got %v", err)
+	}
+}
@@ -1,3 +1,8 @@
 module nand2tetris/projects/08
 
 go 1.17
+
+require assembler v0.0.0
+
+// the script runner of the VM emulator is the one of the CPU emulator
+replace assembler => ../06
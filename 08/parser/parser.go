@@ -87,6 +87,8 @@ func (p *Parser) parseNextCommand() {
 		p.currCmd = p.parseFlowControlCommand(op)
 	case cmd.C_GOTO:
 		p.currCmd = p.parseFlowControlCommand(op)
+	case cmd.C_IF:
+		p.currCmd = p.parseFlowControlCommand(op)
 	case cmd.C_FUNCTION:
 		p.currCmd = p.parseFunctionCallCommand(op)
 	case cmd.C_CALL:
@@ -0,0 +1,373 @@
+package vm
+
+import (
+	"fmt"
+	"nand2tetris/projects/08/cmd"
+	"nand2tetris/projects/08/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	SP   = 0
+	LCL  = 1
+	ARG  = 2
+	THIS = 3
+	THAT = 4
+
+	tempBase   = 5
+	staticBase = 16
+)
+
+// instruction is a command of a loaded program with the names it needs to
+// be resolved: the file for static and the function for labels.
+type instruction struct {
+	command  cmd.Command
+	file     string
+	function string
+}
+
+// where names the function of the instruction for errors, or its file when
+// it is outside of functions.
+func (inst instruction) where() string {
+	if inst.function == "" {
+		return inst.file
+	}
+
+	return inst.function
+}
+
+// Machine runs VM programs directly, with the stack and the segments kept
+// in a Hack RAM like the VM emulator does. The return address pushed by
+// call is the index of the command after it.
+type Machine struct {
+	RAM [32768]int16
+
+	program   []instruction
+	functions map[string]int
+	labels    map[string]int // function$label
+	statics   map[string]int // file.index
+
+	pc int
+}
+
+// New makes a Machine with nothing loaded.
+func New() *Machine {
+	return &Machine{
+		functions: make(map[string]int),
+		labels:    make(map[string]int),
+		statics:   make(map[string]int),
+	}
+}
+
+// Load reads .vm files. Execution starts at Sys.init when one of them
+// defines it, and at the first command otherwise. RAM is left as it is.
+func (m *Machine) Load(files ...string) error {
+	m.program = nil
+	m.functions = make(map[string]int)
+	m.labels = make(map[string]int)
+	m.statics = make(map[string]int)
+
+	for _, name := range files {
+		if err := m.loadFile(name); err != nil {
+			return err
+		}
+	}
+
+	for _, inst := range m.program {
+		if c, ok := inst.command.(*cmd.CallCommand); ok {
+			if _, ok := m.functions[c.FuncName]; !ok {
+				return fmt.Errorf("%s: function %s is called but not defined", inst.file, c.FuncName)
+			}
+		}
+	}
+
+	m.pc = 0
+	if start, ok := m.functions["Sys.init"]; ok {
+		m.pc = start
+	}
+
+	return nil
+}
+
+// LoadDir loads every .vm file in dir.
+func (m *Machine) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.vm"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no .vm files in %s", dir)
+	}
+
+	return m.Load(files...)
+}
+
+func (m *Machine) loadFile(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("can't open file: %s", name)
+	}
+	defer file.Close()
+
+	class := strings.TrimSuffix(filepath.Base(name), ".vm")
+	function := ""
+	start := len(m.program)
+
+	p := parser.New(file)
+	for p.HasMoreCommands() {
+		p.Advance()
+		if p.CommandType() == "" {
+			continue
+		}
+
+		command := p.Command()
+		switch c := command.(type) {
+		case *cmd.InvalidCommand:
+			return fmt.Errorf("%s: %s", name, c.ErrorMessage())
+		case *cmd.FunctionCommand:
+			function = c.FuncName
+			if _, ok := m.functions[function]; ok {
+				return fmt.Errorf("%s: function %s is defined twice", name, function)
+			}
+			m.functions[function] = len(m.program)
+		case *cmd.LabelCommand:
+			// labels are not commands that run, like in the VM emulator
+			m.labels[function+"$"+c.Label] = len(m.program)
+
+			continue
+		}
+
+		m.program = append(m.program, instruction{command: command, file: class, function: function})
+	}
+
+	for _, inst := range m.program[start:] {
+		var label string
+		switch c := inst.command.(type) {
+		case *cmd.GotoCommand:
+			label = c.Label
+		case *cmd.IfGotoCommand:
+			label = c.Label
+		default:
+			continue
+		}
+		if _, ok := m.labels[inst.function+"$"+label]; !ok {
+			return fmt.Errorf("%s: label %s is not defined in %s", name, label, inst.function)
+		}
+	}
+
+	return nil
+}
+
+// Halted reports whether PC is past the last command.
+func (m *Machine) Halted() bool {
+	return m.pc >= len(m.program)
+}
+
+// PC returns the index of the next command.
+func (m *Machine) PC() int {
+	return m.pc
+}
+
+// Command returns the next command, or nil when the machine has halted.
+func (m *Machine) Command() cmd.Command {
+	if m.Halted() {
+		return nil
+	}
+
+	return m.program[m.pc].command
+}
+
+// Step executes one command. A halted machine stays where it is.
+func (m *Machine) Step() error {
+	if m.Halted() {
+		return nil
+	}
+
+	inst := m.program[m.pc]
+	if err := m.checkStack(inst.command); err != nil {
+		return fmt.Errorf("%s: %v", inst.where(), err)
+	}
+	m.pc++
+
+	switch c := inst.command.(type) {
+	case *cmd.PushCommand:
+		address, err := m.address(inst, c.Segment, c.Index)
+		if err != nil {
+			return fmt.Errorf("%s: %v", inst.where(), err)
+		}
+		if address < 0 {
+			m.push(c.Index)
+		} else {
+			m.push(m.RAM[address])
+		}
+	case *cmd.PopCommand:
+		address, err := m.address(inst, c.Segment, c.Index)
+		if err != nil {
+			return fmt.Errorf("%s: %v", inst.where(), err)
+		}
+		if address < 0 {
+			return fmt.Errorf("%s: can't pop to constant", inst.where())
+		}
+		m.RAM[address] = m.pop()
+	case *cmd.AddCommand:
+		y, x := m.pop(), m.pop()
+		m.push(x + y)
+	case *cmd.SubCommand:
+		y, x := m.pop(), m.pop()
+		m.push(x - y)
+	case *cmd.NegCommand:
+		m.push(-m.pop())
+	case *cmd.EqCommand:
+		y, x := m.pop(), m.pop()
+		m.push(truth(x == y))
+	case *cmd.GtCommand:
+		y, x := m.pop(), m.pop()
+		m.push(truth(x > y))
+	case *cmd.LtCommand:
+		y, x := m.pop(), m.pop()
+		m.push(truth(x < y))
+	case *cmd.AndCommand:
+		y, x := m.pop(), m.pop()
+		m.push(x & y)
+	case *cmd.OrCommand:
+		y, x := m.pop(), m.pop()
+		m.push(x | y)
+	case *cmd.NotCommand:
+		m.push(^m.pop())
+	case *cmd.GotoCommand:
+		m.pc = m.labels[inst.function+"$"+c.Label]
+	case *cmd.IfGotoCommand:
+		if m.pop() != 0 {
+			m.pc = m.labels[inst.function+"$"+c.Label]
+		}
+	case *cmd.FunctionCommand:
+		for i := 0; i < c.NumOfArgs; i++ {
+			m.push(0)
+		}
+	case *cmd.CallCommand:
+		m.push(int16(m.pc))
+		m.push(m.RAM[LCL])
+		m.push(m.RAM[ARG])
+		m.push(m.RAM[THIS])
+		m.push(m.RAM[THAT])
+		m.RAM[ARG] = m.RAM[SP] - int16(c.NumOfArgs) - 5
+		m.RAM[LCL] = m.RAM[SP]
+		m.pc = m.functions[c.FuncName]
+	case *cmd.ReturnCommand:
+		frame := m.RAM[LCL]
+		if frame < 5 || m.RAM[ARG] < 0 {
+			return fmt.Errorf("%s: return without a frame", inst.where())
+		}
+		ret := m.RAM[frame-5]
+		m.RAM[m.RAM[ARG]] = m.pop()
+		m.RAM[SP] = m.RAM[ARG] + 1
+		m.RAM[THAT] = m.RAM[frame-1]
+		m.RAM[THIS] = m.RAM[frame-2]
+		m.RAM[ARG] = m.RAM[frame-3]
+		m.RAM[LCL] = m.RAM[frame-4]
+		m.pc = int(ret)
+	default:
+		return fmt.Errorf("%s: can't execute %T", inst.where(), c)
+	}
+
+	return nil
+}
+
+// address returns the RAM address of segment[index], or -1 for constant.
+func (m *Machine) address(inst instruction, segment string, index int16) (int, error) {
+	switch segment {
+	case "constant":
+		return -1, nil
+	case "local":
+		return m.offset(segment, LCL, index)
+	case "argument":
+		return m.offset(segment, ARG, index)
+	case "this":
+		return m.offset(segment, THIS, index)
+	case "that":
+		return m.offset(segment, THAT, index)
+	case "pointer":
+		if index > 1 {
+			return 0, fmt.Errorf("pointer %d is out of range", index)
+		}
+		return THIS + int(index), nil
+	case "temp":
+		if index > 7 {
+			return 0, fmt.Errorf("temp %d is out of range", index)
+		}
+		return tempBase + int(index), nil
+	case "static":
+		// each file gets its statics in the order they are first used
+		name := fmt.Sprintf("%s.%d", inst.file, index)
+		address, ok := m.statics[name]
+		if !ok {
+			address = staticBase + len(m.statics)
+			m.statics[name] = address
+		}
+		return address, nil
+	}
+
+	return 0, fmt.Errorf("unknown segment %s", segment)
+}
+
+// offset returns the address of index in the segment the register points to.
+func (m *Machine) offset(segment string, register int, index int16) (int, error) {
+	address := int(m.RAM[register]) + int(index)
+	if address < 0 || address >= len(m.RAM) {
+		return 0, fmt.Errorf("%s %d is out of RAM", segment, index)
+	}
+
+	return address, nil
+}
+
+// checkStack makes sure that command finds the values it pops on the stack
+// and has room for the values it pushes, before it changes anything.
+func (m *Machine) checkStack(command cmd.Command) error {
+	var pops, pushes int
+	switch c := command.(type) {
+	case *cmd.PushCommand:
+		pushes = 1
+	case *cmd.PopCommand, *cmd.IfGotoCommand, *cmd.ReturnCommand:
+		pops = 1
+	case *cmd.NegCommand, *cmd.NotCommand:
+		pops, pushes = 1, 1
+	case *cmd.AddCommand, *cmd.SubCommand, *cmd.EqCommand, *cmd.GtCommand, *cmd.LtCommand, *cmd.AndCommand, *cmd.OrCommand:
+		pops, pushes = 2, 1
+	case *cmd.FunctionCommand:
+		pushes = c.NumOfArgs
+	case *cmd.CallCommand:
+		pushes = 5
+	}
+
+	sp := int(m.RAM[SP])
+	if sp-pops < 0 {
+		return fmt.Errorf("stack underflow, SP is %d", sp)
+	}
+	// SP must stay a positive 16 bit number after the pushes
+	if sp-pops+pushes > len(m.RAM)-1 {
+		return fmt.Errorf("stack overflow, SP is %d", sp)
+	}
+
+	return nil
+}
+
+func (m *Machine) push(value int16) {
+	m.RAM[m.RAM[SP]] = value
+	m.RAM[SP]++
+}
+
+func (m *Machine) pop() int16 {
+	m.RAM[SP]--
+
+	return m.RAM[m.RAM[SP]]
+}
+
+func truth(b bool) int16 {
+	if b {
+		return -1
+	}
+
+	return 0
+}
@@ -0,0 +1,135 @@
+package vme
+
+import (
+	"assembler/tst"
+	"fmt"
+	"nand2tetris/projects/08/vm"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// registers are the names scripts use for the pointers of the stack and
+// the segments.
+var registers = map[string]int{
+	"sp":       vm.SP,
+	"local":    vm.LCL,
+	"argument": vm.ARG,
+	"this":     vm.THIS,
+	"that":     vm.THAT,
+}
+
+// Simulator runs VM emulator scripts on a vm.Machine. Scripts are read and
+// run by the tst package of the assembler, like CPU emulator scripts.
+type Simulator struct {
+	machine *vm.Machine
+}
+
+// NewSimulator makes a Simulator on a new vm.Machine.
+func NewSimulator() *Simulator {
+	return &Simulator{machine: vm.New()}
+}
+
+// Machine returns the machine the scripts run on.
+func (s *Simulator) Machine() *vm.Machine {
+	return s.machine
+}
+
+// NewRunner makes a Runner for scripts whose files are relative to dir.
+func NewRunner(dir string) *tst.Runner {
+	return tst.NewSimulatorRunner(dir, NewSimulator())
+}
+
+// RunFile runs the script in file, with the files it names relative to its
+// directory. A failed comparison is returned as a *tst.Mismatch.
+func RunFile(file string) error {
+	return tst.RunSimulatorFile(file, NewSimulator())
+}
+
+// Load loads a .vm file, or every .vm file of dir when no file is given.
+func (s *Simulator) Load(dir string, args []string) error {
+	switch len(args) {
+	case 0:
+		return s.machine.LoadDir(dir)
+	case 1:
+		if filepath.Ext(args[0]) != ".vm" {
+			return fmt.Errorf("can't load %s, only .vm files", args[0])
+		}
+
+		return s.machine.Load(filepath.Join(dir, args[0]))
+	}
+
+	return fmt.Errorf("load needs one .vm file or none")
+}
+
+// Exec runs vmstep, the only command of the VM emulator.
+func (s *Simulator) Exec(command tst.Command) (bool, error) {
+	if command.Name == "vmstep" {
+		return true, s.machine.Step()
+	}
+
+	return false, nil
+}
+
+func (s *Simulator) Get(name string) (uint16, string, error) {
+	address, err := s.address(name)
+	if err != nil {
+		return 0, "", err
+	}
+
+	value := s.machine.RAM[address]
+
+	return uint16(value), strconv.Itoa(int(value)), nil
+}
+
+func (s *Simulator) Set(name string, value uint16) error {
+	address, err := s.address(name)
+	if err != nil {
+		return err
+	}
+	s.machine.RAM[address] = int16(value)
+
+	return nil
+}
+
+// address returns the RAM address of a variable: RAM[n], one of the
+// registers sp, local, argument, this and that, or an entry of a segment
+// like local[2] or temp[0].
+func (s *Simulator) address(name string) (int, error) {
+	if register, ok := registers[name]; ok {
+		return register, nil
+	}
+
+	i := strings.IndexByte(name, '[')
+	if i < 0 || !strings.HasSuffix(name, "]") {
+		return 0, fmt.Errorf("unknown variable %q", name)
+	}
+
+	n, err := strconv.Atoi(name[i+1 : len(name)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q has no valid index", name)
+	}
+
+	var address int
+	switch segment := name[:i]; segment {
+	case "RAM":
+		address = n
+	case "temp":
+		if n > 7 {
+			return 0, fmt.Errorf("%q is out of range", name)
+		}
+		address = 5 + n
+	default:
+		register, ok := registers[segment]
+		if !ok || segment == "sp" {
+			return 0, fmt.Errorf("unknown variable %q", name)
+		}
+		address = int(s.machine.RAM[register]) + n
+	}
+
+	if address < 0 || address >= len(s.machine.RAM) {
+		return 0, fmt.Errorf("%q is not a RAM address", name)
+	}
+
+	return address, nil
+}